@@ -0,0 +1,55 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/mateors/llmg/chains"
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/prompts"
+)
+
+// checkPromptFits returns ErrPromptTooLong if the chain is an LLM chain whose
+// model reports a context length too small for the formatted prompt. It lets
+// the executor fail with a clear error instead of whatever the model does
+// with a truncated prompt. Chains and models that cannot be checked pass.
+func checkPromptFits(ctx context.Context, chain chains.Chain, inputs map[string]any) error {
+	llmChain, ok := chain.(*chains.LLMChain)
+	if !ok || llmChain.LLM == nil || llmChain.Prompt == nil {
+		return nil
+	}
+
+	caps, err := llms.GetCapabilities(ctx, llmChain.LLM)
+	if errors.Is(err, llms.ErrCapabilitiesUnknown) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if caps.ContextLength == 0 {
+		return nil
+	}
+
+	values := maps.Clone(inputs)
+	if llmChain.Memory != nil {
+		memoryValues, err := llmChain.Memory.LoadMemoryVariables(ctx, inputs)
+		if err != nil {
+			return err
+		}
+		maps.Copy(values, memoryValues)
+	}
+
+	// Formatting errors are reported by the chain itself.
+	prompt, err := prompts.FormatPromptContext(ctx, llmChain.Prompt, values)
+	if err != nil {
+		return nil
+	}
+
+	if tokens := llms.CountTokens(caps.Model, prompt.String()); tokens > caps.ContextLength {
+		return fmt.Errorf("%w: prompt is %d tokens but model %q has a context length of %d",
+			ErrPromptTooLong, tokens, caps.Model, caps.ContextLength)
+	}
+	return nil
+}
@@ -20,6 +20,9 @@ var (
 	// ErrInvalidChainReturnType is returned if the internal chain of the agent returns a value in the
 	// "text" filed that is not a string.
 	ErrInvalidChainReturnType = errors.New("agent chain did not return a string")
+	// ErrPromptTooLong is returned if the prompt of the agent does not fit in the context
+	// length of the model.
+	ErrPromptTooLong = errors.New("agent prompt exceeds the context length of the model")
 )
 
 // ParserErrorHandler is the struct used to handle parse errors from the agent in the executor. If
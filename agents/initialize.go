@@ -65,8 +65,8 @@ func (e *Executor) Call(ctx context.Context, inputValues map[string]any, _ ...ch
 		}
 	}
 
-	if e.CallbacksHandler != nil {
-		e.CallbacksHandler.HandleAgentFinish(ctx, schema.AgentFinish{
+	if handler := callbacks.Combine(ctx, e.CallbacksHandler); handler != nil {
+		handler.HandleAgentFinish(ctx, schema.AgentFinish{
 			ReturnValues: map[string]any{"output": ErrNotFinished.Error()},
 		})
 	}
@@ -102,8 +102,8 @@ func (e *Executor) doIteration( // nolint
 	}
 
 	if finish != nil {
-		if e.CallbacksHandler != nil {
-			e.CallbacksHandler.HandleAgentFinish(ctx, *finish)
+		if handler := callbacks.Combine(ctx, e.CallbacksHandler); handler != nil {
+			handler.HandleAgentFinish(ctx, *finish)
 		}
 		return steps, e.getReturn(finish, steps), nil
 	}
@@ -124,8 +124,8 @@ func (e *Executor) doAction(
 	nameToTool map[string]tools.Tool,
 	action schema.AgentAction,
 ) ([]schema.AgentStep, error) {
-	if e.CallbacksHandler != nil {
-		e.CallbacksHandler.HandleAgentAction(ctx, action)
+	if handler := callbacks.Combine(ctx, e.CallbacksHandler); handler != nil {
+		handler.HandleAgentAction(ctx, action)
 	}
 
 	tool, ok := nameToTool[strings.ToUpper(action.Tool)]
@@ -136,6 +136,7 @@ func (e *Executor) doAction(
 		}), nil
 	}
 
+	ctx, _ = callbacks.StartRun(ctx, callbacks.RunTypeTool, tool.Name())
 	observation, err := tool.Call(ctx, action.ToolInput)
 	if err != nil {
 		return nil, err
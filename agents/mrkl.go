@@ -73,13 +73,16 @@ func (a *OneShotZeroAgent) Plan(
 	fullInputs["agent_scratchpad"] = constructMrklScratchPad(intermediateSteps)
 	fullInputs["today"] = time.Now().Format("January 02, 2006")
 
+	// The models send the chunks to the handlers in ctx, they only stream
+	// with a streaming function.
 	var stream func(ctx context.Context, chunk []byte) error
+	ctx = callbacks.WithHandlers(ctx, a.CallbacksHandler)
+	if callbacks.HandlerFromContext(ctx) != nil {
+		stream = func(context.Context, []byte) error { return nil }
+	}
 
-	if a.CallbacksHandler != nil {
-		stream = func(ctx context.Context, chunk []byte) error {
-			a.CallbacksHandler.HandleStreamingFunc(ctx, chunk)
-			return nil
-		}
+	if err := checkPromptFits(ctx, a.Chain, fullInputs); err != nil {
+		return nil, nil, err
 	}
 
 	output, err := chains.Predict(
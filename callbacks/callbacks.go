@@ -27,9 +27,39 @@ type Handler interface {
 	HandleAgentFinish(ctx context.Context, finish schema.AgentFinish)
 	HandleRetrieverStart(ctx context.Context, query string)
 	HandleRetrieverEnd(ctx context.Context, query string, documents []schema.Document)
+	// HandleStreamingFunc is called by the models for every chunk they
+	// stream, before passing it to the streaming function of the call.
 	HandleStreamingFunc(ctx context.Context, chunk []byte)
 }
 
+// EmbeddingHandler is implemented by handlers that also want the events of
+// embedders. Embedders send them to the handlers that implement it.
+type EmbeddingHandler interface {
+	HandleEmbeddingStart(ctx context.Context, texts []string)
+	HandleEmbeddingEnd(ctx context.Context, vectors [][]float32)
+	HandleEmbeddingError(ctx context.Context, err error)
+}
+
+// VectorStoreHandler is implemented by handlers that also want the events of
+// vector stores. Vector stores send them to the handlers that implement it.
+type VectorStoreHandler interface {
+	HandleVectorStoreAddStart(ctx context.Context, docs []schema.Document)
+	HandleVectorStoreAddEnd(ctx context.Context, ids []string)
+	HandleVectorStoreSearchStart(ctx context.Context, query string, numDocuments int)
+	HandleVectorStoreSearchEnd(ctx context.Context, docs []schema.Document)
+	HandleVectorStoreDeleteStart(ctx context.Context, ids []string)
+	HandleVectorStoreDeleteEnd(ctx context.Context, ids []string)
+	HandleVectorStoreError(ctx context.Context, err error)
+}
+
+// ParseHandler is implemented by handlers that also want the attempts of the
+// output parsers asking a model for a new output when parsing fails, e.g.
+// outputparser.Fixing. The first attempt parses the original output, err is
+// nil if the output was parsed.
+type ParseHandler interface {
+	HandleParseAttempt(ctx context.Context, attempt int, output string, err error)
+}
+
 // HandlerHaver is an interface used to get callbacks handler.
 type HandlerHaver interface {
 	GetCallbackHandler() Handler
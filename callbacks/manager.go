@@ -0,0 +1,252 @@
+package callbacks
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/schema"
+)
+
+// Manager is a handler that fans out every callback to multiple handlers, in
+// the order they were added.
+type Manager struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+var (
+	_ Handler            = (*Manager)(nil)
+	_ EmbeddingHandler   = (*Manager)(nil)
+	_ VectorStoreHandler = (*Manager)(nil)
+	_ ParseHandler       = (*Manager)(nil)
+)
+
+// NewCallbackManager creates a manager fanning out to the given handlers.
+func NewCallbackManager(handlers ...Handler) *Manager {
+	m := &Manager{}
+	for _, h := range handlers {
+		m.AddHandler(h)
+	}
+	return m
+}
+
+// AddHandler adds a handler. Nil handlers and handlers that were already added
+// are ignored; handlers of a type that is not comparable cannot be recognized
+// as already added.
+func (m *Manager) AddHandler(h Handler) {
+	if other, ok := h.(*Manager); ok && other == m {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers = appendHandler(m.handlers, h)
+}
+
+// RemoveHandler removes a handler. Handlers of a type that is not comparable
+// cannot be removed.
+func (m *Manager) RemoveHandler(h Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers = slices.DeleteFunc(m.handlers, func(other Handler) bool {
+		return sameHandler(other, h)
+	})
+}
+
+// Handlers returns the handlers of the manager.
+func (m *Manager) Handlers() []Handler {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.handlers)
+}
+
+func (m *Manager) each(fn func(h Handler)) {
+	for _, h := range m.Handlers() {
+		fn(h)
+	}
+}
+
+func (m *Manager) HandleText(ctx context.Context, text string) {
+	m.each(func(h Handler) { h.HandleText(ctx, text) })
+}
+
+func (m *Manager) HandleLLMGenerateContentStart(ctx context.Context, ms []llms.MessageContent) {
+	m.each(func(h Handler) { h.HandleLLMGenerateContentStart(ctx, ms) })
+}
+
+func (m *Manager) HandleLLMGenerateContentEnd(ctx context.Context, res *llms.ContentResponse) {
+	m.each(func(h Handler) { h.HandleLLMGenerateContentEnd(ctx, res) })
+}
+
+func (m *Manager) HandleLLMError(ctx context.Context, err error) {
+	m.each(func(h Handler) { h.HandleLLMError(ctx, err) })
+}
+
+func (m *Manager) HandleChainStart(ctx context.Context, inputs map[string]any) {
+	m.each(func(h Handler) { h.HandleChainStart(ctx, inputs) })
+}
+
+func (m *Manager) HandleChainEnd(ctx context.Context, outputs map[string]any) {
+	m.each(func(h Handler) { h.HandleChainEnd(ctx, outputs) })
+}
+
+func (m *Manager) HandleChainError(ctx context.Context, err error) {
+	m.each(func(h Handler) { h.HandleChainError(ctx, err) })
+}
+
+func (m *Manager) HandleToolStart(ctx context.Context, input string) {
+	m.each(func(h Handler) { h.HandleToolStart(ctx, input) })
+}
+
+func (m *Manager) HandleToolEnd(ctx context.Context, output string) {
+	m.each(func(h Handler) { h.HandleToolEnd(ctx, output) })
+}
+
+func (m *Manager) HandleToolError(ctx context.Context, err error) {
+	m.each(func(h Handler) { h.HandleToolError(ctx, err) })
+}
+
+func (m *Manager) HandleAgentAction(ctx context.Context, action schema.AgentAction) {
+	m.each(func(h Handler) { h.HandleAgentAction(ctx, action) })
+}
+
+func (m *Manager) HandleAgentFinish(ctx context.Context, finish schema.AgentFinish) {
+	m.each(func(h Handler) { h.HandleAgentFinish(ctx, finish) })
+}
+
+func (m *Manager) HandleRetrieverStart(ctx context.Context, query string) {
+	m.each(func(h Handler) { h.HandleRetrieverStart(ctx, query) })
+}
+
+func (m *Manager) HandleRetrieverEnd(ctx context.Context, query string, documents []schema.Document) {
+	m.each(func(h Handler) { h.HandleRetrieverEnd(ctx, query, documents) })
+}
+
+func (m *Manager) HandleStreamingFunc(ctx context.Context, chunk []byte) {
+	m.each(func(h Handler) { h.HandleStreamingFunc(ctx, chunk) })
+}
+
+// HandleEmbeddingStart and the other embedding and vector store events are
+// only sent to the handlers that implement EmbeddingHandler or
+// VectorStoreHandler.
+func (m *Manager) HandleEmbeddingStart(ctx context.Context, texts []string) {
+	m.each(func(h Handler) {
+		if eh, ok := h.(EmbeddingHandler); ok {
+			eh.HandleEmbeddingStart(ctx, texts)
+		}
+	})
+}
+
+func (m *Manager) HandleEmbeddingEnd(ctx context.Context, vectors [][]float32) {
+	m.each(func(h Handler) {
+		if eh, ok := h.(EmbeddingHandler); ok {
+			eh.HandleEmbeddingEnd(ctx, vectors)
+		}
+	})
+}
+
+func (m *Manager) HandleEmbeddingError(ctx context.Context, err error) {
+	m.each(func(h Handler) {
+		if eh, ok := h.(EmbeddingHandler); ok {
+			eh.HandleEmbeddingError(ctx, err)
+		}
+	})
+}
+
+func (m *Manager) HandleVectorStoreAddStart(ctx context.Context, docs []schema.Document) {
+	m.each(func(h Handler) {
+		if vh, ok := h.(VectorStoreHandler); ok {
+			vh.HandleVectorStoreAddStart(ctx, docs)
+		}
+	})
+}
+
+func (m *Manager) HandleVectorStoreAddEnd(ctx context.Context, ids []string) {
+	m.each(func(h Handler) {
+		if vh, ok := h.(VectorStoreHandler); ok {
+			vh.HandleVectorStoreAddEnd(ctx, ids)
+		}
+	})
+}
+
+func (m *Manager) HandleVectorStoreSearchStart(ctx context.Context, query string, numDocuments int) {
+	m.each(func(h Handler) {
+		if vh, ok := h.(VectorStoreHandler); ok {
+			vh.HandleVectorStoreSearchStart(ctx, query, numDocuments)
+		}
+	})
+}
+
+func (m *Manager) HandleVectorStoreSearchEnd(ctx context.Context, docs []schema.Document) {
+	m.each(func(h Handler) {
+		if vh, ok := h.(VectorStoreHandler); ok {
+			vh.HandleVectorStoreSearchEnd(ctx, docs)
+		}
+	})
+}
+
+func (m *Manager) HandleVectorStoreDeleteStart(ctx context.Context, ids []string) {
+	m.each(func(h Handler) {
+		if vh, ok := h.(VectorStoreHandler); ok {
+			vh.HandleVectorStoreDeleteStart(ctx, ids)
+		}
+	})
+}
+
+func (m *Manager) HandleVectorStoreDeleteEnd(ctx context.Context, ids []string) {
+	m.each(func(h Handler) {
+		if vh, ok := h.(VectorStoreHandler); ok {
+			vh.HandleVectorStoreDeleteEnd(ctx, ids)
+		}
+	})
+}
+
+func (m *Manager) HandleVectorStoreError(ctx context.Context, err error) {
+	m.each(func(h Handler) {
+		if vh, ok := h.(VectorStoreHandler); ok {
+			vh.HandleVectorStoreError(ctx, err)
+		}
+	})
+}
+
+// HandleParseAttempt is only sent to the handlers that implement
+// ParseHandler.
+func (m *Manager) HandleParseAttempt(ctx context.Context, attempt int, output string, err error) {
+	m.each(func(h Handler) {
+		if ph, ok := h.(ParseHandler); ok {
+			ph.HandleParseAttempt(ctx, attempt, output, err)
+		}
+	})
+}
+
+// appendHandler appends h to handlers unless it is nil or already present.
+func appendHandler(handlers []Handler, h Handler) []Handler {
+	if h == nil || slices.ContainsFunc(handlers, func(other Handler) bool { return sameHandler(other, h) }) {
+		return handlers
+	}
+	return append(handlers, h)
+}
+
+// appendFlattened is appendHandler with the handlers of managers appended
+// one by one, so that they can be deduplicated too.
+func appendFlattened(handlers []Handler, h Handler) []Handler {
+	if m, ok := h.(*Manager); ok {
+		for _, inner := range m.Handlers() {
+			handlers = appendFlattened(handlers, inner)
+		}
+		return handlers
+	}
+	return appendHandler(handlers, h)
+}
+
+// sameHandler reports whether a and b are the same handler, without panicking
+// on handlers of a type that is not comparable.
+func sameHandler(a, b Handler) bool {
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	if ta != tb || !ta.Comparable() {
+		return false
+	}
+	return a == b
+}
@@ -0,0 +1,437 @@
+package callbacks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/schema"
+)
+
+const (
+	_defaultMetricsNamespace = "llmg"
+	_unknownLabelValue       = "unknown"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency
+// histograms.
+var DefaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120} //nolint:gochecknoglobals,lll
+
+// MetricsHandler is a handler that maintains counters and histograms of LLM,
+// chain, tool, retriever, embedding and vector store runs and serves them in
+// the Prometheus text exposition format. Runs are identified with RunFromContext, so the handler
+// must receive the events through the run-scoped callbacks, e.g. with
+// WithHandlers.
+type MetricsHandler struct {
+	SimpleHandler
+
+	mu        sync.Mutex
+	namespace string
+	buckets   []float64
+	metrics   []metric
+	// firstToken holds the LLM runs that already streamed a chunk.
+	firstToken map[string]struct{}
+
+	llmRequests, llmErrors, promptTokens, completionTokens *counterVec
+	chainRuns, chainErrors, toolCalls, toolErrors          *counterVec
+	retrieverCalls                                         *counterVec
+	embeddingRequests, embeddingErrors, embeddingTexts     *counterVec
+	vectorStoreOperations, vectorStoreErrors               *counterVec
+	llmLatency, llmTimeToFirstToken                        *histogramVec
+	chainLatency, toolLatency, retrieverLatency            *histogramVec
+	embeddingLatency, vectorStoreLatency                   *histogramVec
+}
+
+var (
+	_ Handler            = (*MetricsHandler)(nil)
+	_ EmbeddingHandler   = (*MetricsHandler)(nil)
+	_ VectorStoreHandler = (*MetricsHandler)(nil)
+	_ http.Handler       = (*MetricsHandler)(nil)
+)
+
+// MetricsOption is a function that configures a MetricsHandler.
+type MetricsOption func(*MetricsHandler)
+
+// WithMetricsNamespace sets the prefix of the metric names. Defaults to "llmg".
+func WithMetricsNamespace(namespace string) MetricsOption {
+	return func(h *MetricsHandler) {
+		h.namespace = namespace
+	}
+}
+
+// WithLatencyBuckets sets the upper bounds, in seconds, of the latency
+// histograms. Defaults to DefaultLatencyBuckets.
+func WithLatencyBuckets(buckets []float64) MetricsOption {
+	return func(h *MetricsHandler) {
+		h.buckets = slices.Sorted(slices.Values(buckets))
+	}
+}
+
+// NewMetricsHandler creates a metrics handler.
+func NewMetricsHandler(opts ...MetricsOption) *MetricsHandler {
+	h := &MetricsHandler{
+		namespace:  _defaultMetricsNamespace,
+		buckets:    DefaultLatencyBuckets,
+		firstToken: make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	h.llmRequests = h.counter("llm_requests_total", "Number of LLM requests.", "model")
+	h.llmErrors = h.counter("llm_errors_total", "Number of failed LLM requests.", "model")
+	h.llmLatency = h.histogram("llm_request_duration_seconds", "Latency of LLM requests.", "model")
+	h.llmTimeToFirstToken = h.histogram("llm_time_to_first_token_seconds",
+		"Time until the first streamed chunk of LLM requests.", "model")
+	h.promptTokens = h.counter("llm_prompt_tokens_total", "Number of prompt tokens.", "model")
+	h.completionTokens = h.counter("llm_completion_tokens_total", "Number of completion tokens.", "model")
+	h.chainRuns = h.counter("chain_runs_total", "Number of chain runs.", "chain")
+	h.chainErrors = h.counter("chain_errors_total", "Number of failed chain runs.", "chain")
+	h.chainLatency = h.histogram("chain_duration_seconds", "Duration of chain runs.", "chain")
+	h.toolCalls = h.counter("tool_calls_total", "Number of tool calls.", "tool")
+	h.toolErrors = h.counter("tool_errors_total", "Number of failed tool calls.", "tool")
+	h.toolLatency = h.histogram("tool_duration_seconds", "Duration of tool calls.", "tool")
+	h.retrieverCalls = h.counter("retriever_calls_total", "Number of retriever calls.", "retriever")
+	h.retrieverLatency = h.histogram("retriever_duration_seconds", "Duration of retriever calls.", "retriever")
+	h.embeddingRequests = h.counter("embedding_requests_total", "Number of embedding requests.", "embedder")
+	h.embeddingErrors = h.counter("embedding_errors_total", "Number of failed embedding requests.", "embedder")
+	h.embeddingTexts = h.counter("embedding_texts_total", "Number of embedded texts.", "embedder")
+	h.embeddingLatency = h.histogram("embedding_duration_seconds", "Latency of embedding requests.", "embedder")
+	h.vectorStoreOperations = h.counter("vectorstore_operations_total",
+		"Number of vector store operations.", "operation")
+	h.vectorStoreErrors = h.counter("vectorstore_errors_total",
+		"Number of failed vector store operations.", "operation")
+	h.vectorStoreLatency = h.histogram("vectorstore_duration_seconds",
+		"Duration of vector store operations.", "operation")
+	return h
+}
+
+func (h *MetricsHandler) HandleLLMGenerateContentStart(ctx context.Context, _ []llms.MessageContent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.llmRequests.inc(runName(ctx, RunTypeLLM), 1)
+}
+
+func (h *MetricsHandler) HandleLLMGenerateContentEnd(ctx context.Context, res *llms.ContentResponse) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	model := runName(ctx, RunTypeLLM)
+	h.llmLatency.observe(model, runSeconds(ctx))
+	if usage := tokenUsage(res); usage != nil {
+		h.promptTokens.inc(model, float64(usage.Prompt))
+		h.completionTokens.inc(model, float64(usage.Completion))
+	}
+	h.endRun(ctx)
+}
+
+func (h *MetricsHandler) HandleLLMError(ctx context.Context, _ error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	model := runName(ctx, RunTypeLLM)
+	h.llmErrors.inc(model, 1)
+	h.llmLatency.observe(model, runSeconds(ctx))
+	h.endRun(ctx)
+}
+
+func (h *MetricsHandler) HandleStreamingFunc(ctx context.Context, _ []byte) {
+	run := RunFromContext(ctx)
+	if run == nil || run.Type != RunTypeLLM {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.firstToken[run.ID]; ok {
+		return
+	}
+	h.firstToken[run.ID] = struct{}{}
+	h.llmTimeToFirstToken.observe(run.Name, time.Since(run.StartTime).Seconds())
+}
+
+func (h *MetricsHandler) HandleChainStart(ctx context.Context, _ map[string]any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.chainRuns.inc(runName(ctx, RunTypeChain), 1)
+}
+
+func (h *MetricsHandler) HandleChainEnd(ctx context.Context, _ map[string]any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.chainLatency.observe(runName(ctx, RunTypeChain), runSeconds(ctx))
+}
+
+func (h *MetricsHandler) HandleChainError(ctx context.Context, _ error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	chain := runName(ctx, RunTypeChain)
+	h.chainErrors.inc(chain, 1)
+	h.chainLatency.observe(chain, runSeconds(ctx))
+}
+
+func (h *MetricsHandler) HandleToolStart(ctx context.Context, _ string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.toolCalls.inc(runName(ctx, RunTypeTool), 1)
+}
+
+func (h *MetricsHandler) HandleToolEnd(ctx context.Context, _ string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.toolLatency.observe(runName(ctx, RunTypeTool), runSeconds(ctx))
+}
+
+func (h *MetricsHandler) HandleToolError(ctx context.Context, _ error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	tool := runName(ctx, RunTypeTool)
+	h.toolErrors.inc(tool, 1)
+	h.toolLatency.observe(tool, runSeconds(ctx))
+}
+
+func (h *MetricsHandler) HandleRetrieverStart(ctx context.Context, _ string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.retrieverCalls.inc(runName(ctx, RunTypeRetriever), 1)
+}
+
+func (h *MetricsHandler) HandleRetrieverEnd(ctx context.Context, _ string, _ []schema.Document) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.retrieverLatency.observe(runName(ctx, RunTypeRetriever), runSeconds(ctx))
+}
+
+func (h *MetricsHandler) HandleEmbeddingStart(ctx context.Context, texts []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	embedder := runName(ctx, RunTypeEmbedding)
+	h.embeddingRequests.inc(embedder, 1)
+	h.embeddingTexts.inc(embedder, float64(len(texts)))
+}
+
+func (h *MetricsHandler) HandleEmbeddingEnd(ctx context.Context, _ [][]float32) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.embeddingLatency.observe(runName(ctx, RunTypeEmbedding), runSeconds(ctx))
+}
+
+func (h *MetricsHandler) HandleEmbeddingError(ctx context.Context, _ error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	embedder := runName(ctx, RunTypeEmbedding)
+	h.embeddingErrors.inc(embedder, 1)
+	h.embeddingLatency.observe(embedder, runSeconds(ctx))
+}
+
+func (h *MetricsHandler) HandleVectorStoreAddStart(ctx context.Context, _ []schema.Document) {
+	h.vectorStoreStart(ctx)
+}
+
+func (h *MetricsHandler) HandleVectorStoreAddEnd(ctx context.Context, _ []string) {
+	h.vectorStoreEnd(ctx)
+}
+
+func (h *MetricsHandler) HandleVectorStoreSearchStart(ctx context.Context, _ string, _ int) {
+	h.vectorStoreStart(ctx)
+}
+
+func (h *MetricsHandler) HandleVectorStoreSearchEnd(ctx context.Context, _ []schema.Document) {
+	h.vectorStoreEnd(ctx)
+}
+
+func (h *MetricsHandler) HandleVectorStoreDeleteStart(ctx context.Context, _ []string) {
+	h.vectorStoreStart(ctx)
+}
+
+func (h *MetricsHandler) HandleVectorStoreDeleteEnd(ctx context.Context, _ []string) {
+	h.vectorStoreEnd(ctx)
+}
+
+func (h *MetricsHandler) HandleVectorStoreError(ctx context.Context, _ error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	operation := runName(ctx, RunTypeVectorStore)
+	h.vectorStoreErrors.inc(operation, 1)
+	h.vectorStoreLatency.observe(operation, runSeconds(ctx))
+}
+
+func (h *MetricsHandler) vectorStoreStart(ctx context.Context) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.vectorStoreOperations.inc(runName(ctx, RunTypeVectorStore), 1)
+}
+
+func (h *MetricsHandler) vectorStoreEnd(ctx context.Context) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.vectorStoreLatency.observe(runName(ctx, RunTypeVectorStore), runSeconds(ctx))
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format.
+func (h *MetricsHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	var buf bytes.Buffer
+	if _, err := h.WriteTo(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (h *MetricsHandler) WriteTo(w io.Writer) (int64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, m := range h.metrics {
+		m.write(&buf)
+	}
+	return buf.WriteTo(w)
+}
+
+// endRun forgets the state kept for the run in ctx.
+func (h *MetricsHandler) endRun(ctx context.Context) {
+	if run := RunFromContext(ctx); run != nil {
+		delete(h.firstToken, run.ID)
+	}
+}
+
+func (h *MetricsHandler) counter(name, help, label string) *counterVec {
+	c := &counterVec{
+		name:   h.namespace + "_" + name,
+		help:   help,
+		label:  label,
+		values: make(map[string]float64),
+	}
+	h.metrics = append(h.metrics, c)
+	return c
+}
+
+func (h *MetricsHandler) histogram(name, help, label string) *histogramVec {
+	hv := &histogramVec{
+		name:    h.namespace + "_" + name,
+		help:    help,
+		label:   label,
+		buckets: h.buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+	h.metrics = append(h.metrics, hv)
+	return hv
+}
+
+// runName returns the name of the run in ctx if it is of the given type.
+func runName(ctx context.Context, typ RunType) string {
+	if run := RunFromContext(ctx); run != nil && run.Type == typ && run.Name != "" {
+		return run.Name
+	}
+	return _unknownLabelValue
+}
+
+// runSeconds returns the time since the start of the run in ctx.
+func runSeconds(ctx context.Context) float64 {
+	if run := RunFromContext(ctx); run != nil {
+		return time.Since(run.StartTime).Seconds()
+	}
+	return 0
+}
+
+type metric interface {
+	write(w *bytes.Buffer)
+}
+
+type counterVec struct {
+	name, help, label string
+	values            map[string]float64
+}
+
+func (c *counterVec) inc(labelValue string, v float64) {
+	c.values[labelValue] += v
+}
+
+func (c *counterVec) write(w *bytes.Buffer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, lv := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s{%s=%q} %s\n", c.name, c.label, escapeLabelValue(lv), formatFloat(c.values[lv]))
+	}
+}
+
+type histogramVec struct {
+	name, help, label string
+	buckets           []float64
+	series            map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (hv *histogramVec) observe(labelValue string, v float64) {
+	s, ok := hv.series[labelValue]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(hv.buckets))}
+		hv.series[labelValue] = s
+	}
+	for i, upper := range hv.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (hv *histogramVec) write(w *bytes.Buffer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", hv.name, hv.help, hv.name)
+	for _, lv := range sortedKeys(hv.series) {
+		s := hv.series[lv]
+		label := fmt.Sprintf("%s=%q", hv.label, escapeLabelValue(lv))
+		for i, upper := range hv.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", hv.name, label, formatFloat(upper), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", hv.name, label, s.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", hv.name, label, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", hv.name, label, s.count)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// escapeLabelValue prepares a label value for %q, which escapes backslashes,
+// quotes and newlines as the exposition format expects but would also escape
+// other non-printable characters, which the format does not know.
+func escapeLabelValue(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '"' || r == '\\' || strconv.IsPrint(r) {
+			return r
+		}
+		return '?'
+	}, s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,106 @@
+package callbacks
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RunType is the kind of component a run belongs to.
+type RunType string
+
+const (
+	RunTypeChain       RunType = "chain"
+	RunTypeLLM         RunType = "llm"
+	RunTypeTool        RunType = "tool"
+	RunTypeRetriever   RunType = "retriever"
+	RunTypeEmbedding   RunType = "embedding"
+	RunTypeVectorStore RunType = "vectorstore"
+)
+
+// Run is one execution of a chain, LLM, tool, retriever, embedder or vector
+// store operation. Runs nest: a chain calling an LLM is the parent of the
+// LLM's run. Handlers get the current run with RunFromContext.
+type Run struct {
+	// ID identifies the run.
+	ID string
+	// ParentID is the ID of the enclosing run, empty for a root run.
+	ParentID string
+	// TraceID is the ID of the root run, shared by all runs of a tree.
+	TraceID string
+	// Type is the kind of component being run.
+	Type RunType
+	// Name is the name of the component, e.g. the tool name or the model.
+	Name string
+	// StartTime is when the run started.
+	StartTime time.Time
+}
+
+type runKey struct{}
+
+type handlersKey struct{}
+
+// StartRun starts a run as a child of the run in ctx, if any, and returns a
+// context carrying it.
+func StartRun(ctx context.Context, typ RunType, name string) (context.Context, *Run) {
+	run := &Run{
+		ID:        uuid.NewString(),
+		Type:      typ,
+		Name:      name,
+		StartTime: time.Now(),
+	}
+	run.TraceID = run.ID
+	if parent := RunFromContext(ctx); parent != nil {
+		run.ParentID = parent.ID
+		run.TraceID = parent.TraceID
+	}
+	return context.WithValue(ctx, runKey{}, run), run
+}
+
+// RunFromContext returns the current run, or nil if there is none.
+func RunFromContext(ctx context.Context) *Run {
+	run, _ := ctx.Value(runKey{}).(*Run)
+	return run
+}
+
+// WithHandlers returns a context carrying the given handlers in addition to
+// the ones already in ctx. Every component run with the context, and every
+// component they call in turn, reports to these handlers.
+func WithHandlers(ctx context.Context, handlers ...Handler) context.Context {
+	current := contextHandlers(ctx)
+	result := current
+	for _, h := range handlers {
+		result = appendFlattened(result, h)
+	}
+	if len(result) == len(current) {
+		return ctx
+	}
+	return context.WithValue(ctx, handlersKey{}, result)
+}
+
+// HandlerFromContext returns a handler fanning out to the handlers in ctx, or
+// nil if there are none.
+func HandlerFromContext(ctx context.Context) Handler { //nolint:ireturn
+	return Combine(ctx, nil)
+}
+
+// Combine returns a handler fanning out to the handlers in ctx and to local,
+// the handler set on the component itself, or nil if there are none. A handler
+// found both in ctx and as local is only called once.
+func Combine(ctx context.Context, local Handler) Handler { //nolint:ireturn
+	handlers := appendFlattened(contextHandlers(ctx), local)
+	switch len(handlers) {
+	case 0:
+		return nil
+	case 1:
+		return handlers[0]
+	}
+	return &Manager{handlers: handlers}
+}
+
+func contextHandlers(ctx context.Context) []Handler {
+	handlers, _ := ctx.Value(handlersKey{}).([]Handler)
+	// Force a copy on append, the slice is shared by all derived contexts.
+	return handlers[:len(handlers):len(handlers)]
+}
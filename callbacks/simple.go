@@ -10,7 +10,12 @@ import (
 
 type SimpleHandler struct{}
 
-var _ Handler = SimpleHandler{}
+var (
+	_ Handler            = SimpleHandler{}
+	_ EmbeddingHandler   = SimpleHandler{}
+	_ VectorStoreHandler = SimpleHandler{}
+	_ ParseHandler       = SimpleHandler{}
+)
 
 func (SimpleHandler) HandleText(context.Context, string)                                   {}
 func (SimpleHandler) HandleLLMStart(context.Context, []string)                             {}
@@ -28,3 +33,14 @@ func (SimpleHandler) HandleAgentFinish(context.Context, schema.AgentFinish)
 func (SimpleHandler) HandleRetrieverStart(context.Context, string)                         {}
 func (SimpleHandler) HandleRetrieverEnd(context.Context, string, []schema.Document)        {}
 func (SimpleHandler) HandleStreamingFunc(context.Context, []byte)                          {}
+func (SimpleHandler) HandleEmbeddingStart(context.Context, []string)                       {}
+func (SimpleHandler) HandleEmbeddingEnd(context.Context, [][]float32)                      {}
+func (SimpleHandler) HandleEmbeddingError(context.Context, error)                          {}
+func (SimpleHandler) HandleVectorStoreAddStart(context.Context, []schema.Document)         {}
+func (SimpleHandler) HandleVectorStoreAddEnd(context.Context, []string)                    {}
+func (SimpleHandler) HandleVectorStoreSearchStart(context.Context, string, int)            {}
+func (SimpleHandler) HandleVectorStoreSearchEnd(context.Context, []schema.Document)        {}
+func (SimpleHandler) HandleVectorStoreDeleteStart(context.Context, []string)               {}
+func (SimpleHandler) HandleVectorStoreDeleteEnd(context.Context, []string)                 {}
+func (SimpleHandler) HandleVectorStoreError(context.Context, error)                        {}
+func (SimpleHandler) HandleParseAttempt(context.Context, int, string, error)               {}
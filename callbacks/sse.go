@@ -0,0 +1,275 @@
+package callbacks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mateors/llmg/schema"
+)
+
+const _defaultSSEHeartbeat = 15 * time.Second
+
+// SSE events written by SSEHandler. The data of every event is a JSON object.
+const (
+	// SSEEventToken is a streamed chunk: {"run_id", "text"}.
+	SSEEventToken = "token"
+	// SSEEventAgentAction is a tool call decided by an agent: {"run_id",
+	// "tool", "tool_input", "log"}.
+	SSEEventAgentAction = "agent_action"
+	// SSEEventToolResult is the output or error of a tool: {"run_id", "tool",
+	// "output"} or {"run_id", "tool", "error"}.
+	SSEEventToolResult = "tool_result"
+	// SSEEventFinal is the outputs of the outermost chain: {"run_id",
+	// "outputs"}.
+	SSEEventFinal = "final"
+	// SSEEventError is the error of the outermost chain: {"run_id", "error"}.
+	SSEEventError = "error"
+)
+
+var (
+	// ErrStreamingUnsupported is returned when the response writer cannot flush.
+	ErrStreamingUnsupported = errors.New("response writer does not support streaming")
+	// ErrStreamClosed is returned when writing to a closed event stream.
+	ErrStreamClosed = errors.New("event stream closed")
+)
+
+// SSEHandler is a handler writing the progress of a run to an HTTP response
+// as Server-Sent Events: streamed tokens, agent actions, tool results and the
+// final outputs, see the SSEEvent constants. Every event is flushed as soon
+// as it is written, and a comment is sent periodically to keep the
+// connection open.
+type SSEHandler struct {
+	SimpleHandler
+
+	mu        sync.Mutex
+	w         http.ResponseWriter
+	flusher   http.Flusher
+	cancel    context.CancelFunc
+	nextID    int
+	err       error
+	closed    bool
+	heartbeat time.Duration
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+var _ Handler = (*SSEHandler)(nil)
+
+// SSEOption is a function that configures an SSEHandler.
+type SSEOption func(*SSEHandler)
+
+// WithSSEHeartbeat sets how often a comment is sent to keep the connection
+// open. Zero disables the heartbeat. Defaults to 15 seconds.
+func WithSSEHeartbeat(interval time.Duration) SSEOption {
+	return func(h *SSEHandler) {
+		h.heartbeat = interval
+	}
+}
+
+// NewSSEHandler starts an event stream on w and returns a handler writing to
+// it, together with a context to run the chain with. The context carries the
+// handler, see WithHandlers, and is canceled when the client disconnects or
+// the stream cannot be written anymore, which stops the chain. Tokens are only
+// streamed if the LLM calls stream, e.g. with StreamingFunc as streaming
+// function, and run with the context.
+//
+// Close must be called before the HTTP handler returns:
+//
+//	h, ctx, err := callbacks.NewSSEHandler(w, r)
+//	if err != nil {
+//		http.Error(w, err.Error(), http.StatusInternalServerError)
+//		return
+//	}
+//	defer h.Close()
+//	_, err = chains.Call(ctx, chain, inputs, chains.WithStreamingFunc(h.StreamingFunc))
+func NewSSEHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	opts ...SSEOption,
+) (*SSEHandler, context.Context, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, nil, ErrStreamingUnsupported
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	h := &SSEHandler{
+		w:         w,
+		flusher:   flusher,
+		cancel:    cancel,
+		heartbeat: _defaultSSEHeartbeat,
+		stop:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	if h.heartbeat > 0 {
+		h.wg.Add(1)
+		go h.keepAlive(ctx)
+	}
+	return h, WithHandlers(ctx, h), nil
+}
+
+// Send writes a custom event. The data is encoded as JSON.
+func (h *SSEHandler) Send(event string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return h.write(event, b)
+}
+
+// Err returns the first error that occurred while writing, if any.
+func (h *SSEHandler) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+// Close stops the heartbeat and cancels the context returned by
+// NewSSEHandler. No event is written after Close returns.
+func (h *SSEHandler) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	close(h.stop)
+	h.mu.Unlock()
+
+	h.wg.Wait()
+	h.cancel()
+	return nil
+}
+
+// StreamingFunc is a streaming function making the LLM calls stream. The
+// token events are written by HandleStreamingFunc, as the models send the
+// chunks to the handlers of the context. It fails once the stream is closed
+// or broken, which stops the generation.
+func (h *SSEHandler) StreamingFunc(context.Context, []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return ErrStreamClosed
+	}
+	return h.err
+}
+
+func (h *SSEHandler) HandleStreamingFunc(ctx context.Context, chunk []byte) {
+	h.send(ctx, SSEEventToken, map[string]any{"text": string(chunk)})
+}
+
+func (h *SSEHandler) HandleAgentAction(ctx context.Context, action schema.AgentAction) {
+	h.send(ctx, SSEEventAgentAction, map[string]any{
+		"tool":       action.Tool,
+		"tool_input": action.ToolInput,
+		"log":        action.Log,
+	})
+}
+
+func (h *SSEHandler) HandleToolEnd(ctx context.Context, output string) {
+	h.send(ctx, SSEEventToolResult, map[string]any{"tool": runName(ctx, RunTypeTool), "output": output})
+}
+
+func (h *SSEHandler) HandleToolError(ctx context.Context, err error) {
+	h.send(ctx, SSEEventToolResult, map[string]any{"tool": runName(ctx, RunTypeTool), "error": err.Error()})
+}
+
+func (h *SSEHandler) HandleChainEnd(ctx context.Context, outputs map[string]any) {
+	if isRootRun(ctx) {
+		h.send(ctx, SSEEventFinal, map[string]any{"outputs": outputs})
+	}
+}
+
+func (h *SSEHandler) HandleChainError(ctx context.Context, err error) {
+	if isRootRun(ctx) {
+		h.send(ctx, SSEEventError, map[string]any{"error": err.Error()})
+	}
+}
+
+func (h *SSEHandler) send(ctx context.Context, event string, data map[string]any) {
+	_ = h.write(event, h.encode(ctx, data))
+}
+
+// encode encodes data with the ID of the current run added.
+func (h *SSEHandler) encode(ctx context.Context, data map[string]any) []byte {
+	if run := RunFromContext(ctx); run != nil {
+		data["run_id"] = run.ID
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		// The outputs may hold values that cannot be encoded, keep the event.
+		b, _ = json.Marshal(map[string]any{"run_id": data["run_id"], "data": fmt.Sprintf("%+v", data)})
+	}
+	return b
+}
+
+func (h *SSEHandler) write(event string, data []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return ErrStreamClosed
+	}
+	if h.err != nil {
+		return h.err
+	}
+
+	h.nextID++
+	_, err := fmt.Fprintf(h.w, "id: %d\nevent: %s\ndata: %s\n\n", h.nextID, event, data)
+	h.flushOrFail(err)
+	return err
+}
+
+func (h *SSEHandler) keepAlive(ctx context.Context) {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			h.mu.Lock()
+			if !h.closed && h.err == nil {
+				_, err := fmt.Fprint(h.w, ": ping\n\n")
+				h.flushOrFail(err)
+			}
+			h.mu.Unlock()
+		}
+	}
+}
+
+// flushOrFail flushes the response, or records err and cancels the run if the
+// write failed. Must be called with mu held.
+func (h *SSEHandler) flushOrFail(err error) {
+	if err != nil {
+		h.err = err
+		h.cancel()
+		return
+	}
+	h.flusher.Flush()
+}
+
+// isRootRun reports whether the current run is the outermost one.
+func isRootRun(ctx context.Context) bool {
+	run := RunFromContext(ctx)
+	return run == nil || run.ParentID == ""
+}
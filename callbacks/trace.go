@@ -0,0 +1,325 @@
+package callbacks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/schema"
+)
+
+// Trace events, the Event field of a TraceRecord.
+const (
+	TraceEventText           = "text"
+	TraceEventLLMStart       = "llm_start"
+	TraceEventLLMEnd         = "llm_end"
+	TraceEventLLMError       = "llm_error"
+	TraceEventChainStart     = "chain_start"
+	TraceEventChainEnd       = "chain_end"
+	TraceEventChainError     = "chain_error"
+	TraceEventToolStart      = "tool_start"
+	TraceEventToolEnd        = "tool_end"
+	TraceEventToolError      = "tool_error"
+	TraceEventAgentAction    = "agent_action"
+	TraceEventAgentFinish    = "agent_finish"
+	TraceEventRetrieverStart = "retriever_start"
+	TraceEventRetrieverEnd   = "retriever_end"
+	TraceEventStreamChunk    = "stream_chunk"
+
+	TraceEventEmbeddingStart         = "embedding_start"
+	TraceEventEmbeddingEnd           = "embedding_end"
+	TraceEventEmbeddingError         = "embedding_error"
+	TraceEventVectorStoreAddStart    = "vectorstore_add_start"
+	TraceEventVectorStoreAddEnd      = "vectorstore_add_end"
+	TraceEventVectorStoreSearchStart = "vectorstore_search_start"
+	TraceEventVectorStoreSearchEnd   = "vectorstore_search_end"
+	TraceEventVectorStoreDeleteStart = "vectorstore_delete_start"
+	TraceEventVectorStoreDeleteEnd   = "vectorstore_delete_end"
+	TraceEventVectorStoreError       = "vectorstore_error"
+	TraceEventParseAttempt           = "parse_attempt"
+)
+
+// TraceRecord is one line written by TraceHandler. Records of the same run
+// share a RunID, and runs form a tree through ParentID, like spans.
+type TraceRecord struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	RunID    string    `json:"run_id,omitempty"`
+	ParentID string    `json:"parent_id,omitempty"`
+	TraceID  string    `json:"trace_id,omitempty"`
+	RunType  RunType   `json:"run_type,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	// RunStart is when the run started.
+	RunStart time.Time `json:"run_start,omitzero"`
+	// DurationMS is the time since the start of the run, set on end and error
+	// events.
+	DurationMS float64 `json:"duration_ms,omitempty"`
+	// Data is the payload of the event: the inputs, prompt, response, tool
+	// input or output, query and documents, agent action or finish.
+	Data any `json:"data,omitempty"`
+	// Error is the error of an error event.
+	Error string `json:"error,omitempty"`
+	// Tokens is the token usage reported with an LLM response.
+	Tokens *TokenUsage `json:"tokens,omitempty"`
+}
+
+// TokenUsage is the number of tokens used by an LLM call.
+type TokenUsage struct {
+	Prompt     int `json:"prompt"`
+	Completion int `json:"completion"`
+	Total      int `json:"total"`
+}
+
+// TraceHandler is a handler writing every event as a JSON line, see
+// TraceRecord. Writes are serialized, so a single handler can be shared by
+// concurrent runs.
+type TraceHandler struct {
+	mu            sync.Mutex
+	enc           *json.Encoder
+	err           error
+	includeChunks bool
+}
+
+var (
+	_ Handler            = (*TraceHandler)(nil)
+	_ EmbeddingHandler   = (*TraceHandler)(nil)
+	_ VectorStoreHandler = (*TraceHandler)(nil)
+	_ ParseHandler       = (*TraceHandler)(nil)
+)
+
+// TraceOption is a function that configures a TraceHandler.
+type TraceOption func(*TraceHandler)
+
+// WithTraceStreamChunks sets whether streamed chunks are written, one record
+// each. They are not by default.
+func WithTraceStreamChunks(include bool) TraceOption {
+	return func(h *TraceHandler) {
+		h.includeChunks = include
+	}
+}
+
+// NewTraceHandler creates a handler writing JSON lines to w.
+func NewTraceHandler(w io.Writer, opts ...TraceOption) *TraceHandler {
+	h := &TraceHandler{enc: json.NewEncoder(w)}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Err returns the first error that occurred while writing, if any.
+func (h *TraceHandler) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+func (h *TraceHandler) HandleText(ctx context.Context, text string) {
+	h.write(ctx, TraceRecord{Event: TraceEventText, Data: text})
+}
+
+func (h *TraceHandler) HandleLLMGenerateContentStart(ctx context.Context, ms []llms.MessageContent) {
+	h.write(ctx, TraceRecord{Event: TraceEventLLMStart, Data: ms})
+}
+
+func (h *TraceHandler) HandleLLMGenerateContentEnd(ctx context.Context, res *llms.ContentResponse) {
+	h.write(ctx, TraceRecord{Event: TraceEventLLMEnd, Data: res, Tokens: tokenUsage(res)})
+}
+
+func (h *TraceHandler) HandleLLMError(ctx context.Context, err error) {
+	h.write(ctx, TraceRecord{Event: TraceEventLLMError, Error: err.Error()})
+}
+
+func (h *TraceHandler) HandleChainStart(ctx context.Context, inputs map[string]any) {
+	h.write(ctx, TraceRecord{Event: TraceEventChainStart, Data: inputs})
+}
+
+func (h *TraceHandler) HandleChainEnd(ctx context.Context, outputs map[string]any) {
+	h.write(ctx, TraceRecord{Event: TraceEventChainEnd, Data: outputs})
+}
+
+func (h *TraceHandler) HandleChainError(ctx context.Context, err error) {
+	h.write(ctx, TraceRecord{Event: TraceEventChainError, Error: err.Error()})
+}
+
+func (h *TraceHandler) HandleToolStart(ctx context.Context, input string) {
+	h.write(ctx, TraceRecord{Event: TraceEventToolStart, Data: input})
+}
+
+func (h *TraceHandler) HandleToolEnd(ctx context.Context, output string) {
+	h.write(ctx, TraceRecord{Event: TraceEventToolEnd, Data: output})
+}
+
+func (h *TraceHandler) HandleToolError(ctx context.Context, err error) {
+	h.write(ctx, TraceRecord{Event: TraceEventToolError, Error: err.Error()})
+}
+
+func (h *TraceHandler) HandleAgentAction(ctx context.Context, action schema.AgentAction) {
+	h.write(ctx, TraceRecord{Event: TraceEventAgentAction, Data: action})
+}
+
+func (h *TraceHandler) HandleAgentFinish(ctx context.Context, finish schema.AgentFinish) {
+	h.write(ctx, TraceRecord{Event: TraceEventAgentFinish, Data: finish})
+}
+
+func (h *TraceHandler) HandleRetrieverStart(ctx context.Context, query string) {
+	h.write(ctx, TraceRecord{Event: TraceEventRetrieverStart, Data: query})
+}
+
+func (h *TraceHandler) HandleRetrieverEnd(ctx context.Context, query string, documents []schema.Document) {
+	h.write(ctx, TraceRecord{Event: TraceEventRetrieverEnd, Data: map[string]any{
+		"query":     query,
+		"documents": documents,
+	}})
+}
+
+func (h *TraceHandler) HandleStreamingFunc(ctx context.Context, chunk []byte) {
+	if h.includeChunks {
+		h.write(ctx, TraceRecord{Event: TraceEventStreamChunk, Data: string(chunk)})
+	}
+}
+
+func (h *TraceHandler) HandleEmbeddingStart(ctx context.Context, texts []string) {
+	h.write(ctx, TraceRecord{Event: TraceEventEmbeddingStart, Data: texts})
+}
+
+// HandleEmbeddingEnd records the number and dimensions of the vectors, not
+// the vectors themselves.
+func (h *TraceHandler) HandleEmbeddingEnd(ctx context.Context, vectors [][]float32) {
+	dimensions := 0
+	if len(vectors) > 0 {
+		dimensions = len(vectors[0])
+	}
+	h.write(ctx, TraceRecord{Event: TraceEventEmbeddingEnd, Data: map[string]any{
+		"count":      len(vectors),
+		"dimensions": dimensions,
+	}})
+}
+
+func (h *TraceHandler) HandleEmbeddingError(ctx context.Context, err error) {
+	h.write(ctx, TraceRecord{Event: TraceEventEmbeddingError, Error: err.Error()})
+}
+
+func (h *TraceHandler) HandleVectorStoreAddStart(ctx context.Context, docs []schema.Document) {
+	h.write(ctx, TraceRecord{Event: TraceEventVectorStoreAddStart, Data: docs})
+}
+
+func (h *TraceHandler) HandleVectorStoreAddEnd(ctx context.Context, ids []string) {
+	h.write(ctx, TraceRecord{Event: TraceEventVectorStoreAddEnd, Data: ids})
+}
+
+func (h *TraceHandler) HandleVectorStoreSearchStart(ctx context.Context, query string, numDocuments int) {
+	h.write(ctx, TraceRecord{Event: TraceEventVectorStoreSearchStart, Data: map[string]any{
+		"query":         query,
+		"num_documents": numDocuments,
+	}})
+}
+
+func (h *TraceHandler) HandleVectorStoreSearchEnd(ctx context.Context, docs []schema.Document) {
+	h.write(ctx, TraceRecord{Event: TraceEventVectorStoreSearchEnd, Data: docs})
+}
+
+func (h *TraceHandler) HandleVectorStoreDeleteStart(ctx context.Context, ids []string) {
+	h.write(ctx, TraceRecord{Event: TraceEventVectorStoreDeleteStart, Data: ids})
+}
+
+func (h *TraceHandler) HandleVectorStoreDeleteEnd(ctx context.Context, ids []string) {
+	h.write(ctx, TraceRecord{Event: TraceEventVectorStoreDeleteEnd, Data: ids})
+}
+
+func (h *TraceHandler) HandleVectorStoreError(ctx context.Context, err error) {
+	h.write(ctx, TraceRecord{Event: TraceEventVectorStoreError, Error: err.Error()})
+}
+
+func (h *TraceHandler) HandleParseAttempt(ctx context.Context, attempt int, output string, err error) {
+	record := TraceRecord{Event: TraceEventParseAttempt, Data: map[string]any{
+		"attempt": attempt,
+		"output":  output,
+	}}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	h.write(ctx, record)
+}
+
+func (h *TraceHandler) write(ctx context.Context, record TraceRecord) {
+	record.Time = time.Now()
+	if run := RunFromContext(ctx); run != nil {
+		record.RunID = run.ID
+		record.ParentID = run.ParentID
+		record.TraceID = run.TraceID
+		record.RunType = run.Type
+		record.Name = run.Name
+		record.RunStart = run.StartTime
+		if isEndEvent(record.Event) {
+			record.DurationMS = float64(record.Time.Sub(run.StartTime).Microseconds()) / 1000
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	err := h.enc.Encode(record)
+	if err != nil {
+		// The payload may hold values that cannot be encoded, keep the event.
+		record.Data = fmt.Sprintf("%+v", record.Data)
+		err = h.enc.Encode(record)
+	}
+	if err != nil && h.err == nil {
+		h.err = err
+	}
+}
+
+func isEndEvent(event string) bool {
+	switch event {
+	case TraceEventLLMEnd, TraceEventLLMError,
+		TraceEventChainEnd, TraceEventChainError,
+		TraceEventToolEnd, TraceEventToolError,
+		TraceEventRetrieverEnd,
+		TraceEventEmbeddingEnd, TraceEventEmbeddingError,
+		TraceEventVectorStoreAddEnd, TraceEventVectorStoreSearchEnd,
+		TraceEventVectorStoreDeleteEnd, TraceEventVectorStoreError:
+		return true
+	}
+	return false
+}
+
+// tokenUsage returns the token usage reported in the generation info of the
+// first choice that has any.
+func tokenUsage(res *llms.ContentResponse) *TokenUsage {
+	if res == nil {
+		return nil
+	}
+	for _, choice := range res.Choices {
+		info := choice.GenerationInfo
+		prompt, okPrompt := toInt(info["PromptTokens"])
+		completion, okCompletion := toInt(info["CompletionTokens"])
+		if !okPrompt && !okCompletion {
+			continue
+		}
+		total, ok := toInt(info["TotalTokens"])
+		if !ok {
+			total = prompt + completion
+		}
+		return &TokenUsage{Prompt: prompt, Completion: completion, Total: total}
+	}
+	return nil
+}
+
+func toInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
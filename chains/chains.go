@@ -3,6 +3,7 @@ package chains
 import (
 	"context"
 	"fmt"
+	"reflect"
 
 	"github.com/mateors/llmg/callbacks"
 	"github.com/mateors/llmg/schema"
@@ -23,7 +24,16 @@ type Chain interface {
 }
 
 // Call is the standard function used for executing chains.
+//
+// Each call is a run of its own, nested in the run in ctx if any, see
+// callbacks.StartRun. The callbacks go to the handlers in ctx and to the
+// handler of the chain, which is then passed on to the components the chain
+// calls.
 func Call(ctx context.Context, c Chain, inputValues map[string]any, options ...ChainCallOption) (map[string]any, error) { // nolint: lll
+	ctx, run := callbacks.StartRun(ctx, callbacks.RunTypeChain, chainName(c))
+	ctx = callbacks.WithHandlers(ctx, getChainCallbackHandler(c))
+	ctx = withStreamChain(ctx, c, run)
+
 	fullValues := make(map[string]any, 0)
 	for key, value := range inputValues {
 		fullValues[key] = value
@@ -38,7 +48,7 @@ func Call(ctx context.Context, c Chain, inputValues map[string]any, options ...C
 		fullValues[key] = value
 	}
 
-	callbacksHandler := getChainCallbackHandler(c)
+	callbacksHandler := callbacks.HandlerFromContext(ctx)
 	if callbacksHandler != nil {
 		callbacksHandler.HandleChainStart(ctx, inputValues)
 	}
@@ -140,6 +150,15 @@ func validateOutputs(c Chain, outputValues map[string]any) error {
 	return nil
 }
 
+// chainName returns the name of the type of the chain, e.g. "LLMChain".
+func chainName(c Chain) string {
+	t := reflect.TypeOf(c)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
 func getChainCallbackHandler(c Chain) callbacks.Handler {
 	if handlerHaver, ok := c.(callbacks.HandlerHaver); ok {
 		return handlerHaver.GetCallbackHandler()
@@ -0,0 +1,188 @@
+package chains
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/memory"
+	"github.com/mateors/llmg/prompts"
+	"github.com/mateors/llmg/schema"
+)
+
+const (
+	_conversationalRetrievalQADefaultInputKey = "question"
+	_conversationalRetrievalQAGeneratedKey    = "generated_question"
+	_condenseQuestionHistoryVariable          = "chat_history"
+)
+
+//nolint:lll
+const _condenseQuestionTemplate = `Given the following conversation and a follow up question, rephrase the follow up question to be a standalone question, in its original language.
+
+Chat History:
+{{.chat_history}}
+Follow Up Input: {{.question}}
+Standalone question:`
+
+// ConversationalRetrievalQA is a chain answering follow-up questions from
+// documents. The condense question chain first rewrites the question into a
+// standalone one given the chat history of the memory, e.g. "what about the
+// second one?" into a question naming it. The documents relevant to that
+// question are then retrieved and combined by a chain to answer, like
+// RetrievalQA. Without history, the question is used as is.
+type ConversationalRetrievalQA struct {
+	// Retriever gets the documents relevant to the standalone question.
+	Retriever schema.Retriever
+	// Memory holds the chat history. The answer is saved to it under the
+	// "text" output key, which memories need to be configured with when
+	// there are several output keys, e.g. memory.WithOutputKey("text").
+	Memory schema.Memory
+	// CombineDocumentsChain answers from the "input_documents" and
+	// "question" inputs.
+	CombineDocumentsChain Chain
+	// CondenseQuestionChain rewrites the question from the "chat_history"
+	// and "question" inputs.
+	CondenseQuestionChain Chain
+	// InputKey is the input key of the question. Defaults to "question".
+	InputKey string
+	// RephraseQuestion sets whether the combine documents chain is given the
+	// standalone question rather than the original one. Defaults to true.
+	RephraseQuestion bool
+	// ReturnSourceDocuments sets whether the documents are returned under the
+	// "source_documents" output key.
+	ReturnSourceDocuments bool
+	// ReturnGeneratedQuestion sets whether the standalone question is
+	// returned under the "generated_question" output key.
+	ReturnGeneratedQuestion bool
+}
+
+var _ Chain = ConversationalRetrievalQA{}
+
+// NewConversationalRetrievalQA creates a chain rewriting questions with
+// condenseQuestionChain and answering them from the documents of retriever
+// with combineDocumentsChain.
+func NewConversationalRetrievalQA(
+	combineDocumentsChain Chain,
+	condenseQuestionChain Chain,
+	retriever schema.Retriever,
+	memory schema.Memory,
+) ConversationalRetrievalQA {
+	return ConversationalRetrievalQA{
+		Retriever:             retriever,
+		Memory:                memory,
+		CombineDocumentsChain: combineDocumentsChain,
+		CondenseQuestionChain: condenseQuestionChain,
+		InputKey:              _conversationalRetrievalQADefaultInputKey,
+		RephraseQuestion:      true,
+	}
+}
+
+// NewConversationalRetrievalQAFromLLM creates a chain rewriting questions and
+// answering them from all the documents of retriever at once with llm, see
+// LoadStuffQA and LoadCondenseQuestionGenerator.
+func NewConversationalRetrievalQAFromLLM(
+	llm llms.Model,
+	retriever schema.Retriever,
+	memory schema.Memory,
+) ConversationalRetrievalQA {
+	return NewConversationalRetrievalQA(LoadStuffQA(llm), LoadCondenseQuestionGenerator(llm), retriever, memory)
+}
+
+// LoadCondenseQuestionGenerator loads a chain rewriting the "question" input
+// into a standalone question given the "chat_history" input.
+func LoadCondenseQuestionGenerator(llm llms.Model) *LLMChain {
+	prompt := prompts.NewPromptTemplate(_condenseQuestionTemplate, []string{_condenseQuestionHistoryVariable, "question"})
+	return NewLLMChain(llm, prompt)
+}
+
+// Call rewrites the question given the chat history, retrieves the documents
+// relevant to it and answers from them. Rewriting the question is
+// intermediate, see WithFinalOutputOnly.
+func (c ConversationalRetrievalQA) Call(ctx context.Context, values map[string]any, options ...ChainCallOption) (map[string]any, error) { //nolint:lll
+	question, ok := values[c.InputKey].(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: %w: %v", ErrInvalidInputValues, ErrInputValuesWrongType, c.InputKey)
+	}
+
+	history, err := c.chatHistory(ctx, values)
+	if err != nil {
+		return nil, err
+	}
+	generated := question
+	if history != "" {
+		generated, err = Predict(withIntermediate(ctx), c.CondenseQuestionChain, map[string]any{
+			_condenseQuestionHistoryVariable:         history,
+			_combineDocumentsDefaultQuestionVariable: question,
+		}, options...)
+		if err != nil {
+			return nil, fmt.Errorf("condense question: %w", err)
+		}
+	}
+
+	docs, err := c.Retriever.GetRelevantDocuments(ctx, generated)
+	if err != nil {
+		return nil, err
+	}
+
+	answerQuestion := question
+	if c.RephraseQuestion {
+		answerQuestion = generated
+	}
+	result, err := Call(ctx, c.CombineDocumentsChain, map[string]any{
+		_combineDocumentsDefaultInputKey:         docs,
+		_combineDocumentsDefaultQuestionVariable: answerQuestion,
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ReturnSourceDocuments {
+		result[_retrievalQADefaultSourceDocumentKey] = docs
+	}
+	if c.ReturnGeneratedQuestion {
+		result[_conversationalRetrievalQAGeneratedKey] = generated
+	}
+	return result, nil
+}
+
+// chatHistory returns the chat history loaded from the memory as a string,
+// empty if there is none.
+func (c ConversationalRetrievalQA) chatHistory(ctx context.Context, values map[string]any) (string, error) {
+	switch history := values[c.GetMemory().GetMemoryKey(ctx)].(type) {
+	case string:
+		return history, nil
+	case []llms.ChatMessage:
+		return llms.GetBufferString(history, "Human", "AI")
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("%w: %w: chat history is %T", ErrInvalidInputValues, ErrMemoryValuesWrongType, history)
+	}
+}
+
+// GetMemory returns the memory, or a simple memory if there is none.
+func (c ConversationalRetrievalQA) GetMemory() schema.Memory { //nolint:ireturn
+	if c.Memory == nil {
+		return memory.NewSimple()
+	}
+	return c.Memory
+}
+
+// GetInputKeys returns the question input key.
+func (c ConversationalRetrievalQA) GetInputKeys() []string {
+	return []string{c.InputKey}
+}
+
+// GetOutputKeys returns the output keys of the combine documents chain, and
+// the ones of the source documents and the generated question if they are
+// returned.
+func (c ConversationalRetrievalQA) GetOutputKeys() []string {
+	keys := append([]string{}, c.CombineDocumentsChain.GetOutputKeys()...)
+	if c.ReturnSourceDocuments {
+		keys = append(keys, _retrievalQADefaultSourceDocumentKey)
+	}
+	if c.ReturnGeneratedQuestion {
+		keys = append(keys, _conversationalRetrievalQAGeneratedKey)
+	}
+	return keys
+}
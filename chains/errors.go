@@ -1,6 +1,10 @@
 package chains
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/mateors/llmg/llms"
+)
 
 var (
 	// ErrInvalidInputValues is returned if the input values to a chain is invalid.
@@ -39,4 +43,15 @@ var (
 	ErrMultipleOutputsInPredict = errors.New("predict is not supported with a chain that returns multiple values")
 	// ErrChainInitialization is returned if a chain is not initialized appropriately.
 	ErrChainInitialization = errors.New("error initializing chain")
+
+	// ErrEmptyResponse is returned when the model returns no choice. It is
+	// llms.ErrEmptyResponse.
+	ErrEmptyResponse = llms.ErrEmptyResponse
+
+	// ErrDocumentsExceedTokenMax is returned when documents cannot be collapsed
+	// into the token budget of a map reduce chain.
+	ErrDocumentsExceedTokenMax = errors.New("documents cannot be collapsed into the token budget")
+	// ErrNoDocuments is returned when a chain needing documents is called
+	// without any.
+	ErrNoDocuments = errors.New("no documents")
 )
@@ -51,17 +51,17 @@ func NewLLMChain(llm llms.Model, prompt prompts.FormatPrompter, opts ...ChainCal
 // directly, use rather the Call or Run function if the prompt only requires one input
 // value.
 func (c LLMChain) Call(ctx context.Context, values map[string]any, options ...ChainCallOption) (map[string]any, error) {
-	promptValue, err := c.Prompt.FormatPrompt(values)
+	promptValue, err := prompts.FormatPromptContext(ctx, c.Prompt, values)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := llms.GenerateFromSinglePrompt(ctx, c.LLM, promptValue.String(), getLLMCallOptions(options...)...)
+	result, err := c.generate(ctx, promptValue, options...)
 	if err != nil {
 		return nil, err
 	}
 
-	finalOutput, err := c.OutputParser.ParseWithPrompt(result, promptValue)
+	finalOutput, err := c.parse(ctx, result, promptValue)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +69,38 @@ func (c LLMChain) Call(ctx context.Context, values map[string]any, options ...Ch
 	return map[string]any{c.OutputKey: finalOutput}, nil
 }
 
+// generate sends a string prompt as a single human message and any other
+// prompt value, e.g. the one of a chat prompt template, as its messages.
+func (c LLMChain) generate(
+	ctx context.Context,
+	promptValue llms.PromptValue,
+	options ...ChainCallOption,
+) (string, error) {
+	ctx = callbacks.WithHandlers(ctx, getCallbackHandler(options...))
+	if _, ok := promptValue.(prompts.StringPromptValue); ok {
+		return llms.GenerateFromSinglePrompt(ctx, c.LLM, promptValue.String(), getLLMCallOptions(options...)...)
+	}
+
+	messages := llms.ChatMessagesToContent(promptValue.Messages())
+	resp, err := c.LLM.GenerateContent(ctx, messages, getLLMCallOptions(options...)...)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) < 1 {
+		return "", ErrEmptyResponse
+	}
+	return resp.Choices[0].Content, nil
+}
+
+// parse parses the output with the context if the output parser can use one,
+// e.g. to call a model fixing the output.
+func (c LLMChain) parse(ctx context.Context, result string, promptValue llms.PromptValue) (any, error) {
+	if p, ok := c.OutputParser.(schema.ContextOutputParser[any]); ok {
+		return p.ParseWithPromptContext(ctx, result, promptValue)
+	}
+	return c.OutputParser.ParseWithPrompt(result, promptValue)
+}
+
 // GetMemory returns the memory.
 func (c LLMChain) GetMemory() schema.Memory { //nolint:ireturn
 	return c.Memory //nolint:ireturn
@@ -0,0 +1,316 @@
+package chains
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mateors/llmg/memory"
+	"github.com/mateors/llmg/prompts"
+	"github.com/mateors/llmg/schema"
+)
+
+const (
+	_mapReduceDefaultMaxConcurrency = 4
+	_mapReduceDefaultTokenMax       = 3000
+	_intermediateStepsOutputKey     = "intermediate_steps"
+)
+
+// MapReduceDocuments is a chain combining documents too long for a single
+// prompt: every document is first mapped by an LLM chain, concurrently, e.g.
+// to summarize it. While the mapped documents are longer than the token
+// budget, they are collapsed: split into groups fitting the budget, each
+// combined into one by the collapse chain. The remaining documents are
+// finally combined by the reduce chain.
+type MapReduceDocuments struct {
+	// LLMChain maps a document, given in its document variable with the other
+	// input values.
+	LLMChain *LLMChain
+	// ReduceChain combines the mapped documents, given as "input_documents"
+	// with the other input values, e.g. a StuffDocuments chain.
+	ReduceChain Chain
+	// CollapseChain combines a group of documents like ReduceChain while
+	// collapsing. Defaults to ReduceChain.
+	CollapseChain Chain
+	// InputKey is the input key of the []schema.Document to combine.
+	// Defaults to "input_documents".
+	InputKey string
+	// DocumentVariableName is the variable of the LLM chain's prompt a
+	// document is given in. Defaults to "context".
+	DocumentVariableName string
+	// DocumentPrompt formats a document for the LLM chain, see
+	// StuffDocuments. Defaults to the page content.
+	DocumentPrompt prompts.PromptTemplate
+	// MaxConcurrency is the maximum number of documents mapped or groups
+	// collapsed at a time. Defaults to 4.
+	MaxConcurrency int
+	// TokenMax is the token budget of the documents given to the reduce
+	// chain, without its prompt. Defaults to 3000.
+	TokenMax int
+	// Tokenizer counts the tokens of the documents. Defaults to
+	// prompts.ModelTokenizer("").
+	Tokenizer prompts.Tokenizer
+	// ReturnIntermediateSteps sets whether the mapped texts are returned
+	// under the "intermediate_steps" output key.
+	ReturnIntermediateSteps bool
+}
+
+var _ Chain = MapReduceDocuments{}
+
+// NewMapReduceDocuments creates a chain mapping documents with llmChain and
+// combining the results with reduceChain.
+func NewMapReduceDocuments(llmChain *LLMChain, reduceChain Chain) MapReduceDocuments {
+	return MapReduceDocuments{
+		LLMChain:             llmChain,
+		ReduceChain:          reduceChain,
+		InputKey:             _combineDocumentsDefaultInputKey,
+		DocumentVariableName: _combineDocumentsDefaultDocumentVariableName,
+		DocumentPrompt:       defaultDocumentPrompt(),
+		MaxConcurrency:       _mapReduceDefaultMaxConcurrency,
+		TokenMax:             _mapReduceDefaultTokenMax,
+		Tokenizer:            prompts.ModelTokenizer(""),
+	}
+}
+
+// Call maps the documents, collapses them until they fit the token budget and
+// reduces them. The map and collapse calls are intermediate, see
+// WithFinalOutputOnly. It fails with ErrNoDocuments if there are none.
+func (c MapReduceDocuments) Call(ctx context.Context, values map[string]any, options ...ChainCallOption) (map[string]any, error) { //nolint:lll
+	docs, err := documentsInput(values, c.InputKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("%w: %w: %v", ErrInvalidInputValues, ErrNoDocuments, c.InputKey)
+	}
+	otherValues := make(map[string]any, len(values))
+	for key, value := range values {
+		if key != c.InputKey {
+			otherValues[key] = value
+		}
+	}
+
+	mapped, err := c.mapDocuments(withIntermediate(ctx), docs, otherValues, options...)
+	if err != nil {
+		return nil, err
+	}
+	collapsed, err := c.collapse(withIntermediate(ctx), mapped, otherValues, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := Call(ctx, c.ReduceChain, c.combineInputs(collapsed, otherValues), options...)
+	if err != nil {
+		return nil, err
+	}
+	if c.ReturnIntermediateSteps {
+		steps := make([]string, len(mapped))
+		for i, doc := range mapped {
+			steps[i] = doc.PageContent
+		}
+		result[_intermediateStepsOutputKey] = steps
+	}
+	return result, nil
+}
+
+// mapDocuments calls the LLM chain on every document, keeping the metadata of
+// the documents.
+func (c MapReduceDocuments) mapDocuments(
+	ctx context.Context,
+	docs []schema.Document,
+	values map[string]any,
+	options ...ChainCallOption,
+) ([]schema.Document, error) {
+	mapped := make([]schema.Document, len(docs))
+	err := c.forEach(ctx, len(docs), func(ctx context.Context, i int) error {
+		text, err := formatDocument(docs[i], c.DocumentPrompt)
+		if err != nil {
+			return err
+		}
+		inputs := make(map[string]any, len(values)+1)
+		for key, value := range values {
+			inputs[key] = value
+		}
+		inputs[c.DocumentVariableName] = text
+
+		result, err := Predict(ctx, c.LLMChain, inputs, options...)
+		if err != nil {
+			return fmt.Errorf("map document %d: %w", i, err)
+		}
+		mapped[i] = schema.Document{PageContent: result, Metadata: docs[i].Metadata}
+		return nil
+	})
+	return mapped, err
+}
+
+// collapse combines groups of documents until they fit the token budget. It
+// fails if a round does not reduce the number of tokens.
+func (c MapReduceDocuments) collapse(
+	ctx context.Context,
+	docs []schema.Document,
+	values map[string]any,
+	options ...ChainCallOption,
+) ([]schema.Document, error) {
+	collapseChain := c.CollapseChain
+	if collapseChain == nil {
+		collapseChain = c.ReduceChain
+	}
+
+	tokens := c.countTokens(docs...)
+	for tokens > c.TokenMax {
+		groups, err := c.splitDocuments(docs)
+		if err != nil {
+			return nil, err
+		}
+
+		collapsed := make([]schema.Document, len(groups))
+		err = c.forEach(ctx, len(groups), func(ctx context.Context, i int) error {
+			result, err := Predict(ctx, collapseChain, c.combineInputs(groups[i], values), options...)
+			if err != nil {
+				return fmt.Errorf("collapse documents: %w", err)
+			}
+			collapsed[i] = schema.Document{PageContent: result, Metadata: groupMetadata(groups[i])}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		collapsedTokens := c.countTokens(collapsed...)
+		if collapsedTokens >= tokens {
+			return nil, fmt.Errorf("%w: %d tokens after collapsing, budget of %d",
+				ErrDocumentsExceedTokenMax, collapsedTokens, c.TokenMax)
+		}
+		docs, tokens = collapsed, collapsedTokens
+	}
+	return docs, nil
+}
+
+// splitDocuments splits documents, in order, into groups fitting the token
+// budget.
+func (c MapReduceDocuments) splitDocuments(docs []schema.Document) ([][]schema.Document, error) {
+	var (
+		groups [][]schema.Document
+		group  []schema.Document
+		tokens int
+	)
+	for _, doc := range docs {
+		docTokens := c.countTokens(doc)
+		if docTokens > c.TokenMax {
+			return nil, fmt.Errorf("%w: a document has %d tokens, budget of %d",
+				ErrDocumentsExceedTokenMax, docTokens, c.TokenMax)
+		}
+		if len(group) > 0 && tokens+docTokens > c.TokenMax {
+			groups = append(groups, group)
+			group, tokens = nil, 0
+		}
+		group = append(group, doc)
+		tokens += docTokens
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+func (c MapReduceDocuments) combineInputs(docs []schema.Document, values map[string]any) map[string]any {
+	inputs := make(map[string]any, len(values)+1)
+	for key, value := range values {
+		inputs[key] = value
+	}
+	inputs[_combineDocumentsDefaultInputKey] = docs
+	return inputs
+}
+
+func (c MapReduceDocuments) countTokens(docs ...schema.Document) int {
+	tokenizer := c.Tokenizer
+	if tokenizer == nil {
+		tokenizer = prompts.ModelTokenizer("")
+	}
+	tokens := 0
+	for _, doc := range docs {
+		tokens += tokenizer.CountTokens(doc.PageContent)
+	}
+	return tokens
+}
+
+// forEach calls fn for the indexes 0 to n-1, with at most MaxConcurrency
+// calls at a time. It stops at the first error and returns it.
+func (c MapReduceDocuments) forEach(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pending := make(chan int)
+	go func() {
+		defer close(pending)
+		for i := range n {
+			select {
+			case pending <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		once     sync.Once
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for range max(c.MaxConcurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				if err := fn(ctx, i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// GetMemory returns a simple memory.
+func (c MapReduceDocuments) GetMemory() schema.Memory { //nolint:ireturn
+	return memory.NewSimple()
+}
+
+// GetInputKeys returns the documents input key and the input keys of the LLM
+// chain but the document variable.
+func (c MapReduceDocuments) GetInputKeys() []string {
+	keys := []string{c.InputKey}
+	for _, key := range c.LLMChain.GetInputKeys() {
+		if key != c.DocumentVariableName {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// GetOutputKeys returns the output keys of the reduce chain, and
+// "intermediate_steps" if they are returned.
+func (c MapReduceDocuments) GetOutputKeys() []string {
+	keys := append([]string{}, c.ReduceChain.GetOutputKeys()...)
+	if c.ReturnIntermediateSteps {
+		keys = append(keys, _intermediateStepsOutputKey)
+	}
+	return keys
+}
+
+// groupMetadata returns the metadata of the first document of a group, the
+// collapsed document standing for all of them.
+func groupMetadata(docs []schema.Document) map[string]any {
+	if len(docs) == 0 {
+		return nil
+	}
+	return docs[0].Metadata
+}
@@ -65,6 +65,10 @@ type chainCallOption struct {
 
 	// CallbackHandler is the callback handler for Chain
 	CallbackHandler callbacks.Handler
+
+	// finalOutputOnly is whether the chunks of intermediate chains are not
+	// streamed.
+	finalOutputOnly bool
 }
 
 // WithModel is an option for LLM.Call.
@@ -75,15 +79,34 @@ func WithModel(model string) ChainCallOption {
 	}
 }
 
+// getCallbackHandler returns the handler set with WithCallback, if any.
+func getCallbackHandler(options ...ChainCallOption) callbacks.Handler { //nolint:ireturn
+	opts := &chainCallOption{}
+	for _, option := range options {
+		option(opts)
+	}
+	return opts.CallbackHandler
+}
+
 func getLLMCallOptions(options ...ChainCallOption) []llms.CallOption { //nolint:cyclop
 	opts := &chainCallOption{}
 	for _, option := range options {
 		option(opts)
 	}
 	if opts.StreamingFunc == nil && opts.CallbackHandler != nil {
+		// The models send the chunks to the handlers in ctx, which include
+		// the callback handler, see LLMChain.generate. They only stream with
+		// a streaming function.
+		opts.StreamingFunc = func(context.Context, []byte) error { return nil }
+	}
+
+	if opts.StreamingFunc != nil && opts.finalOutputOnly {
+		streamingFunc := opts.StreamingFunc
 		opts.StreamingFunc = func(ctx context.Context, chunk []byte) error {
-			opts.CallbackHandler.HandleStreamingFunc(ctx, chunk)
-			return nil
+			if isIntermediate(ctx) {
+				return nil
+			}
+			return streamingFunc(ctx, chunk)
 		}
 	}
 
@@ -139,6 +162,15 @@ func WithCallback(callbackHandler callbacks.Handler) ChainCallOption {
 	}
 }
 
+// WithFinalOutputOnly is an option for streaming only the chunks of the chains
+// producing the final output, e.g. the last chain of a SequentialChain. The
+// chunks of intermediate chains are not passed to the streaming function.
+func WithFinalOutputOnly() ChainCallOption {
+	return func(o *chainCallOption) {
+		o.finalOutputOnly = true
+	}
+}
+
 // WithStreamingFunc is an option for LLM.Call that allows streaming responses.
 func WithStreamingFunc(streamingFunc func(ctx context.Context, chunk []byte) error) ChainCallOption {
 	return func(o *chainCallOption) {
@@ -0,0 +1,21 @@
+package chains
+
+import (
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/prompts"
+)
+
+//nolint:lll
+const _stuffQATemplate = `Use the following pieces of context to answer the question at the end. If you don't know the answer, just say that you don't know, don't try to make up an answer.
+
+{{.context}}
+
+Question: {{.question}}
+Helpful Answer:`
+
+// LoadStuffQA loads a chain answering the "question" input from all the
+// documents of the "input_documents" input at once, see StuffDocuments.
+func LoadStuffQA(llm llms.Model) StuffDocuments {
+	prompt := prompts.NewPromptTemplate(_stuffQATemplate, []string{"context", "question"})
+	return NewStuffDocuments(NewLLMChain(llm, prompt))
+}
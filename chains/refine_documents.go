@@ -0,0 +1,138 @@
+package chains
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/mateors/llmg/memory"
+	"github.com/mateors/llmg/prompts"
+	"github.com/mateors/llmg/schema"
+)
+
+const _refineDocumentsDefaultInitialResponseName = "existing_answer"
+
+// RefineDocuments is a chain combining documents one after the other: the LLM
+// chain answers from the first document, then the refine LLM chain refines
+// the answer with each next document in turn. It makes one call per
+// document, but every call only holds one document and the answer so far.
+type RefineDocuments struct {
+	// LLMChain answers from the first document, given in its document
+	// variable with the other input values.
+	LLMChain *LLMChain
+	// RefineLLMChain refines the answer, given in its initial response
+	// variable, with a document given in its document variable.
+	RefineLLMChain *LLMChain
+	// InputKey is the input key of the []schema.Document to combine.
+	// Defaults to "input_documents".
+	InputKey string
+	// DocumentVariableName is the variable of the chains' prompts a document
+	// is given in. Defaults to "context".
+	DocumentVariableName string
+	// InitialResponseName is the variable of the refine chain's prompt the
+	// answer so far is given in. Defaults to "existing_answer".
+	InitialResponseName string
+	// DocumentPrompt formats a document, see StuffDocuments. Defaults to the
+	// page content.
+	DocumentPrompt prompts.PromptTemplate
+	// OutputKey is the output key of the final answer. Defaults to "text".
+	OutputKey string
+	// ReturnIntermediateSteps sets whether the successive answers are returned
+	// under the "intermediate_steps" output key.
+	ReturnIntermediateSteps bool
+}
+
+var _ Chain = RefineDocuments{}
+
+// NewRefineDocuments creates a chain answering from the first document with
+// initialLLMChain and refining the answer with refineLLMChain.
+func NewRefineDocuments(initialLLMChain, refineLLMChain *LLMChain) RefineDocuments {
+	return RefineDocuments{
+		LLMChain:             initialLLMChain,
+		RefineLLMChain:       refineLLMChain,
+		InputKey:             _combineDocumentsDefaultInputKey,
+		DocumentVariableName: _combineDocumentsDefaultDocumentVariableName,
+		InitialResponseName:  _refineDocumentsDefaultInitialResponseName,
+		DocumentPrompt:       defaultDocumentPrompt(),
+		OutputKey:            _llmChainDefaultOutputKey,
+	}
+}
+
+// Call answers from the first document and refines the answer with the other
+// ones. All the calls but the last one are intermediate, see
+// WithFinalOutputOnly.
+func (c RefineDocuments) Call(ctx context.Context, values map[string]any, options ...ChainCallOption) (map[string]any, error) { //nolint:lll
+	docs, err := documentsInput(values, c.InputKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("%w: %w: %v", ErrInvalidInputValues, ErrNoDocuments, c.InputKey)
+	}
+
+	var (
+		answer string
+		steps  = make([]string, 0, len(docs))
+	)
+	for i, doc := range docs {
+		text, err := formatDocument(doc, c.DocumentPrompt)
+		if err != nil {
+			return nil, err
+		}
+		inputs := make(map[string]any, len(values)+1)
+		for key, value := range values {
+			if key != c.InputKey {
+				inputs[key] = value
+			}
+		}
+		inputs[c.DocumentVariableName] = text
+
+		chain := c.LLMChain
+		if i > 0 {
+			chain = c.RefineLLMChain
+			inputs[c.InitialResponseName] = answer
+		}
+		stepCtx := ctx
+		if i < len(docs)-1 {
+			stepCtx = withIntermediate(ctx)
+		}
+		answer, err = Predict(stepCtx, chain, inputs, options...)
+		if err != nil {
+			return nil, fmt.Errorf("refine with document %d: %w", i, err)
+		}
+		steps = append(steps, answer)
+	}
+
+	result := map[string]any{c.OutputKey: answer}
+	if c.ReturnIntermediateSteps {
+		result[_intermediateStepsOutputKey] = steps
+	}
+	return result, nil
+}
+
+// GetMemory returns a simple memory.
+func (c RefineDocuments) GetMemory() schema.Memory { //nolint:ireturn
+	return memory.NewSimple()
+}
+
+// GetInputKeys returns the documents input key and the input keys of the LLM
+// chains but the document variable and the initial response variable.
+func (c RefineDocuments) GetInputKeys() []string {
+	keys := []string{c.InputKey}
+	for _, key := range slices.Concat(c.LLMChain.GetInputKeys(), c.RefineLLMChain.GetInputKeys()) {
+		if key != c.DocumentVariableName && key != c.InitialResponseName && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// GetOutputKeys returns the output key, and "intermediate_steps" if they are
+// returned.
+func (c RefineDocuments) GetOutputKeys() []string {
+	keys := []string{c.OutputKey}
+	if c.ReturnIntermediateSteps {
+		keys = append(keys, _intermediateStepsOutputKey)
+	}
+	return keys
+}
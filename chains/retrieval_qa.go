@@ -0,0 +1,97 @@
+package chains
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/memory"
+	"github.com/mateors/llmg/schema"
+)
+
+const (
+	_retrievalQADefaultInputKey              = "query"
+	_retrievalQADefaultSourceDocumentKey     = "source_documents"
+	_combineDocumentsDefaultQuestionVariable = "question"
+)
+
+// RetrievalQA is a chain answering a query from documents: it gets the
+// documents relevant to the query from a retriever, and calls a chain
+// combining them, e.g. the one of LoadStuffQA, with the documents and the
+// query as "question".
+type RetrievalQA struct {
+	// Retriever gets the documents relevant to the query.
+	Retriever schema.Retriever
+	// CombineDocumentsChain answers from the "input_documents" and
+	// "question" inputs.
+	CombineDocumentsChain Chain
+	// InputKey is the input key of the query. Defaults to "query".
+	InputKey string
+	// ReturnSourceDocuments sets whether the documents are returned under the
+	// "source_documents" output key.
+	ReturnSourceDocuments bool
+}
+
+var _ Chain = RetrievalQA{}
+
+// NewRetrievalQA creates a chain answering from the documents of retriever
+// with combineDocumentsChain.
+func NewRetrievalQA(combineDocumentsChain Chain, retriever schema.Retriever) RetrievalQA {
+	return RetrievalQA{
+		Retriever:             retriever,
+		CombineDocumentsChain: combineDocumentsChain,
+		InputKey:              _retrievalQADefaultInputKey,
+	}
+}
+
+// NewRetrievalQAFromLLM creates a chain answering from all the documents of
+// retriever at once with llm, see LoadStuffQA.
+func NewRetrievalQAFromLLM(llm llms.Model, retriever schema.Retriever) RetrievalQA {
+	return NewRetrievalQA(LoadStuffQA(llm), retriever)
+}
+
+// Call retrieves the documents relevant to the query and answers from them.
+func (c RetrievalQA) Call(ctx context.Context, values map[string]any, options ...ChainCallOption) (map[string]any, error) { //nolint:lll
+	query, ok := values[c.InputKey].(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: %w: %v", ErrInvalidInputValues, ErrInputValuesWrongType, c.InputKey)
+	}
+
+	docs, err := c.Retriever.GetRelevantDocuments(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := Call(ctx, c.CombineDocumentsChain, map[string]any{
+		_combineDocumentsDefaultInputKey:         docs,
+		_combineDocumentsDefaultQuestionVariable: query,
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ReturnSourceDocuments {
+		result[_retrievalQADefaultSourceDocumentKey] = docs
+	}
+	return result, nil
+}
+
+// GetMemory returns a simple memory.
+func (c RetrievalQA) GetMemory() schema.Memory { //nolint:ireturn
+	return memory.NewSimple()
+}
+
+// GetInputKeys returns the query input key.
+func (c RetrievalQA) GetInputKeys() []string {
+	return []string{c.InputKey}
+}
+
+// GetOutputKeys returns the output keys of the combine documents chain, and
+// "source_documents" if they are returned.
+func (c RetrievalQA) GetOutputKeys() []string {
+	keys := append([]string{}, c.CombineDocumentsChain.GetOutputKeys()...)
+	if c.ReturnSourceDocuments {
+		keys = append(keys, _retrievalQADefaultSourceDocumentKey)
+	}
+	return keys
+}
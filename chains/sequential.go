@@ -101,8 +101,12 @@ func (c *SequentialChain) validateSeqChain() error {
 func (c *SequentialChain) Call(ctx context.Context, inputs map[string]any, options ...ChainCallOption) (map[string]any, error) { //nolint:lll
 	var outputs map[string]any
 	var err error
-	for _, chain := range c.chains {
-		outputs, err = Call(ctx, chain, inputs, options...)
+	for i, chain := range c.chains {
+		chainCtx := ctx
+		if i < len(c.chains)-1 {
+			chainCtx = withIntermediate(ctx)
+		}
+		outputs, err = Call(chainCtx, chain, inputs, options...)
 		if err != nil {
 			return nil, err
 		}
@@ -177,9 +181,13 @@ func validateSimpleSeq(chains []Chain) error {
 // Use the Run function that handles the memory and other aspects of the chain.
 func (c *SimpleSequentialChain) Call(ctx context.Context, inputs map[string]any, options ...ChainCallOption) (map[string]any, error) { //nolint:lll
 	input := inputs[input]
-	for _, chain := range c.chains {
+	for i, chain := range c.chains {
+		chainCtx := ctx
+		if i < len(c.chains)-1 {
+			chainCtx = withIntermediate(ctx)
+		}
 		var err error
-		input, err = Run(ctx, chain, input, options...)
+		input, err = Run(chainCtx, chain, input, options...)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,79 @@
+package chains
+
+import (
+	"context"
+	"slices"
+
+	"github.com/mateors/llmg/callbacks"
+)
+
+// StreamEvent is a chunk streamed by a chain run with Stream.
+type StreamEvent struct {
+	// RunID is the ID of the run of the chain, see callbacks.Run.
+	RunID string
+	// Chain is the name of the chain, e.g. "LLMChain".
+	Chain string
+	// OutputKey is the output key of the chain, empty if it has several.
+	OutputKey string
+	// Chunk is the streamed chunk.
+	Chunk []byte
+}
+
+// Stream runs a chain like Call, passing every chunk streamed by the LLM
+// calls of the chain, and of the chains it calls, to fn tagged with the chain
+// emitting it. An error of fn stops the chain. Use WithFinalOutputOnly to
+// stream only the chains producing the final output, e.g. the last chain of
+// a SequentialChain. The function fn may be called concurrently if the chain
+// makes concurrent LLM calls.
+//
+// The streaming function replaces any set with WithStreamingFunc.
+func Stream(
+	ctx context.Context,
+	c Chain,
+	inputValues map[string]any,
+	fn func(ctx context.Context, event StreamEvent) error,
+	options ...ChainCallOption,
+) (map[string]any, error) {
+	streamingFunc := func(ctx context.Context, chunk []byte) error {
+		event := StreamEvent{Chunk: chunk}
+		if info, ok := ctx.Value(streamChainKey{}).(streamChain); ok {
+			event.RunID, event.Chain, event.OutputKey = info.runID, info.name, info.outputKey
+		}
+		return fn(ctx, event)
+	}
+	options = append(slices.Clone(options), WithStreamingFunc(streamingFunc))
+	return Call(ctx, c, inputValues, options...)
+}
+
+type streamChainKey struct{}
+
+type intermediateKey struct{}
+
+// streamChain identifies the chain whose LLM calls stream chunks.
+type streamChain struct {
+	runID     string
+	name      string
+	outputKey string
+}
+
+// withStreamChain returns a context carrying the chain being run, which the
+// chunks streamed with the context are tagged with.
+func withStreamChain(ctx context.Context, c Chain, run *callbacks.Run) context.Context {
+	info := streamChain{runID: run.ID, name: run.Name}
+	if keys := c.GetOutputKeys(); len(keys) == 1 {
+		info.outputKey = keys[0]
+	}
+	return context.WithValue(ctx, streamChainKey{}, info)
+}
+
+// withIntermediate returns a context for running a chain whose output is not
+// the final output, e.g. one of the first chains of a SequentialChain. The
+// chunks it streams are dropped with WithFinalOutputOnly.
+func withIntermediate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, intermediateKey{}, true)
+}
+
+func isIntermediate(ctx context.Context) bool {
+	intermediate, _ := ctx.Value(intermediateKey{}).(bool)
+	return intermediate
+}
@@ -0,0 +1,137 @@
+package chains
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mateors/llmg/memory"
+	"github.com/mateors/llmg/prompts"
+	"github.com/mateors/llmg/schema"
+)
+
+const (
+	_combineDocumentsDefaultInputKey             = "input_documents"
+	_combineDocumentsDefaultDocumentVariableName = "context"
+	_stuffDocumentsDefaultSeparator              = "\n\n"
+	_documentPageContentVariable                 = "page_content"
+)
+
+// StuffDocuments is a chain combining documents by formatting them all into
+// the prompt of an LLM chain: every document is formatted with the document
+// prompt, and the results are joined with the separator into the document
+// variable of the prompt.
+type StuffDocuments struct {
+	// LLMChain is the chain called with the documents.
+	LLMChain *LLMChain
+	// InputKey is the input key of the []schema.Document to combine.
+	// Defaults to "input_documents".
+	InputKey string
+	// DocumentVariableName is the variable of the LLM chain's prompt the
+	// documents are formatted into. Defaults to "context".
+	DocumentVariableName string
+	// DocumentPrompt formats a document. It is given the page_content
+	// variable and the metadata of the document. Defaults to the page content.
+	DocumentPrompt prompts.PromptTemplate
+	// Separator joins the formatted documents. Defaults to "\n\n".
+	Separator string
+}
+
+var _ Chain = StuffDocuments{}
+
+// NewStuffDocuments creates a chain formatting documents into the prompt of
+// an LLM chain, in its "context" variable.
+func NewStuffDocuments(llmChain *LLMChain) StuffDocuments {
+	return StuffDocuments{
+		LLMChain:             llmChain,
+		InputKey:             _combineDocumentsDefaultInputKey,
+		DocumentVariableName: _combineDocumentsDefaultDocumentVariableName,
+		DocumentPrompt:       defaultDocumentPrompt(),
+		Separator:            _stuffDocumentsDefaultSeparator,
+	}
+}
+
+// Call formats the documents into the prompt and calls the LLM chain with the
+// other input values.
+func (c StuffDocuments) Call(ctx context.Context, values map[string]any, options ...ChainCallOption) (map[string]any, error) { //nolint:lll
+	docs, err := documentsInput(values, c.InputKey)
+	if err != nil {
+		return nil, err
+	}
+	text, err := formatDocuments(docs, c.DocumentPrompt, c.Separator)
+	if err != nil {
+		return nil, err
+	}
+
+	inputValues := make(map[string]any, len(values))
+	for key, value := range values {
+		if key != c.InputKey {
+			inputValues[key] = value
+		}
+	}
+	inputValues[c.DocumentVariableName] = text
+	return Call(ctx, c.LLMChain, inputValues, options...)
+}
+
+// GetMemory returns a simple memory.
+func (c StuffDocuments) GetMemory() schema.Memory { //nolint:ireturn
+	return memory.NewSimple()
+}
+
+// GetInputKeys returns the documents input key and the input keys of the LLM
+// chain but the document variable.
+func (c StuffDocuments) GetInputKeys() []string {
+	keys := []string{c.InputKey}
+	for _, key := range c.LLMChain.GetInputKeys() {
+		if key != c.DocumentVariableName {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// GetOutputKeys returns the output keys of the LLM chain.
+func (c StuffDocuments) GetOutputKeys() []string {
+	return c.LLMChain.GetOutputKeys()
+}
+
+func defaultDocumentPrompt() prompts.PromptTemplate {
+	return prompts.NewPromptTemplate("{{.page_content}}", []string{_documentPageContentVariable})
+}
+
+// documentsInput returns the []schema.Document input value of key.
+func documentsInput(values map[string]any, key string) ([]schema.Document, error) {
+	value, ok := values[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %w: %v", ErrInvalidInputValues, ErrMissingInputValues, key)
+	}
+	docs, ok := value.([]schema.Document)
+	if !ok {
+		return nil, fmt.Errorf("%w: %w: %v is %T, want []schema.Document",
+			ErrInvalidInputValues, ErrInputValuesWrongType, key, value)
+	}
+	return docs, nil
+}
+
+// formatDocument formats a document with a prompt given its page content and
+// metadata.
+func formatDocument(doc schema.Document, prompt prompts.PromptTemplate) (string, error) {
+	values := make(map[string]any, len(doc.Metadata)+1)
+	for key, value := range doc.Metadata {
+		values[key] = value
+	}
+	values[_documentPageContentVariable] = doc.PageContent
+	return prompt.Format(values)
+}
+
+func formatDocuments(docs []schema.Document, prompt prompts.PromptTemplate, separator string) (string, error) {
+	texts := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		text, err := formatDocument(doc, prompt)
+		if err != nil {
+			return "", err
+		}
+		texts = append(texts, text)
+	}
+	return strings.Join(texts, separator), nil
+}
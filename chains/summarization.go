@@ -0,0 +1,51 @@
+package chains
+
+import (
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/prompts"
+)
+
+const _summarizationTemplate = `Write a concise summary of the following:
+
+
+"{{.context}}"
+
+
+CONCISE SUMMARY:`
+
+const _refineSummarizationTemplate = `Your job is to produce a final summary
+We have provided an existing summary up to a certain point: "{{.existing_answer}}"
+We have the opportunity to refine the existing summary (only if needed) with some more context below.
+------------
+"{{.context}}"
+------------
+
+Given the new context, refine the original summary
+If the context isn't useful, return the original summary.
+
+REFINED SUMMARY:`
+
+// LoadStuffSummarization loads a chain summarizing all the documents of the
+// "input_documents" input at once, see StuffDocuments.
+func LoadStuffSummarization(llm llms.Model) StuffDocuments {
+	return NewStuffDocuments(NewLLMChain(llm, summarizationPrompt()))
+}
+
+// LoadMapReduceSummarization loads a chain summarizing every document of the
+// "input_documents" input, then the summaries, see MapReduceDocuments.
+func LoadMapReduceSummarization(llm llms.Model) MapReduceDocuments {
+	reduceChain := NewStuffDocuments(NewLLMChain(llm, summarizationPrompt()))
+	return NewMapReduceDocuments(NewLLMChain(llm, summarizationPrompt()), reduceChain)
+}
+
+// LoadRefineSummarization loads a chain summarizing the first document of the
+// "input_documents" input and refining the summary with every next one, see
+// RefineDocuments.
+func LoadRefineSummarization(llm llms.Model) RefineDocuments {
+	refinePrompt := prompts.NewPromptTemplate(_refineSummarizationTemplate, []string{"existing_answer", "context"})
+	return NewRefineDocuments(NewLLMChain(llm, summarizationPrompt()), NewLLMChain(llm, refinePrompt))
+}
+
+func summarizationPrompt() prompts.PromptTemplate {
+	return prompts.NewPromptTemplate(_summarizationTemplate, []string{"context"})
+}
@@ -0,0 +1,189 @@
+// Command traceview prints the runs of a trace written by
+// callbacks.TraceHandler as an indented tree with durations and token counts.
+//
+// Usage:
+//
+//	traceview [-v] [-trace id] [file]
+//
+// The trace is read from standard input if no file is given.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mateors/llmg/callbacks"
+)
+
+const _maxDataLength = 120
+
+type run struct {
+	id       string
+	parentID string
+	traceID  string
+	typ      callbacks.RunType
+	name     string
+	start    time.Time
+	duration float64
+	tokens   *callbacks.TokenUsage
+	err      string
+	events   []callbacks.TraceRecord
+	children []*run
+}
+
+func main() {
+	verbose := flag.Bool("v", false, "print the events of every run")
+	traceID := flag.String("trace", "", "only print the trace with this ID")
+	flag.Parse()
+
+	var r io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	roots, err := readRuns(r)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, root := range roots {
+		if *traceID != "" && root.traceID != *traceID {
+			continue
+		}
+		printRun(os.Stdout, root, 0, *verbose)
+	}
+}
+
+// readRuns reads the records and returns the root runs in order of start.
+func readRuns(r io.Reader) ([]*run, error) {
+	runs := make(map[string]*run)
+	var order []*run
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record callbacks.TraceRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		ru, ok := runs[record.RunID]
+		if !ok {
+			ru = &run{
+				id:       record.RunID,
+				parentID: record.ParentID,
+				traceID:  record.TraceID,
+				typ:      record.RunType,
+				name:     record.Name,
+				start:    record.RunStart,
+			}
+			if ru.start.IsZero() {
+				ru.start = record.Time
+			}
+			runs[record.RunID] = ru
+			order = append(order, ru)
+		}
+		ru.events = append(ru.events, record)
+		if record.DurationMS > ru.duration {
+			ru.duration = record.DurationMS
+		}
+		if record.Tokens != nil {
+			ru.tokens = record.Tokens
+		}
+		if record.Error != "" {
+			ru.err = record.Error
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var roots []*run
+	for _, ru := range order {
+		parent, ok := runs[ru.parentID]
+		if ru.parentID == "" || !ok || parent == ru {
+			roots = append(roots, ru)
+			continue
+		}
+		parent.children = append(parent.children, ru)
+	}
+
+	sortRuns(roots)
+	return roots, nil
+}
+
+func sortRuns(runs []*run) {
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].start.Before(runs[j].start) })
+	for _, ru := range runs {
+		sortRuns(ru.children)
+	}
+}
+
+func printRun(w io.Writer, ru *run, depth int, verbose bool) {
+	indent := strings.Repeat("  ", depth)
+
+	label := string(ru.typ)
+	if ru.name != "" {
+		label += " " + ru.name
+	}
+	if ru.id == "" {
+		label = "(no run)"
+	}
+
+	line := fmt.Sprintf("%s%s  %s", indent, label, formatDuration(ru.duration))
+	if ru.tokens != nil {
+		line += fmt.Sprintf("  tokens=%d+%d=%d", ru.tokens.Prompt, ru.tokens.Completion, ru.tokens.Total)
+	}
+	if ru.err != "" {
+		line += "  ERROR: " + truncate(ru.err)
+	}
+	fmt.Fprintln(w, line)
+
+	if verbose {
+		for _, e := range ru.events {
+			detail := e.Error
+			if detail == "" && e.Data != nil {
+				b, err := json.Marshal(e.Data)
+				if err == nil {
+					detail = string(b)
+				}
+			}
+			fmt.Fprintf(w, "%s  · %s %s\n", indent, e.Event, truncate(detail))
+		}
+	}
+
+	for _, child := range ru.children {
+		printRun(w, child, depth+1, verbose)
+	}
+}
+
+func formatDuration(ms float64) string {
+	if ms == 0 {
+		return "-"
+	}
+	return time.Duration(ms * float64(time.Millisecond)).Round(time.Microsecond).String()
+}
+
+func truncate(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > _maxDataLength {
+		return string(r[:_maxDataLength]) + "…"
+	}
+	return s
+}
@@ -2,12 +2,18 @@ package embeddings
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/mateors/llmg/callbacks"
 	"github.com/mateors/llmg/internal/sliceutil"
 )
 
+// ErrNoEmbeddings is returned when the embedder client returns no vector for a
+// text.
+var ErrNoEmbeddings = errors.New("no embeddings returned")
+
 // NewEmbedder creates a new Embedder from the given EmbedderClient, with
 // some options that affect how embedding will be done.
 func NewEmbedder(client EmbedderClient, opts ...Option) (*EmbedderImpl, error) {
@@ -48,8 +54,9 @@ func (e EmbedderClientFunc) CreateEmbedding(ctx context.Context, texts []string)
 type EmbedderImpl struct {
 	client EmbedderClient
 
-	StripNewLines bool
-	BatchSize     int
+	StripNewLines    bool
+	BatchSize        int
+	CallbacksHandler callbacks.Handler
 }
 
 // EmbedQuery embeds a single text.
@@ -58,18 +65,33 @@ func (ei *EmbedderImpl) EmbedQuery(ctx context.Context, text string) ([]float32,
 		text = strings.ReplaceAll(text, "\n", " ")
 	}
 
+	ctx, _ = callbacks.StartRun(ctx, callbacks.RunTypeEmbedding, embedderName(ei.client))
+	handler, _ := callbacks.Combine(ctx, ei.CallbacksHandler).(callbacks.EmbeddingHandler)
+	if handler != nil {
+		handler.HandleEmbeddingStart(ctx, []string{text})
+	}
+
 	emb, err := ei.client.CreateEmbedding(ctx, []string{text})
+	if err == nil && len(emb) == 0 {
+		err = ErrNoEmbeddings
+	}
 	if err != nil {
+		if handler != nil {
+			handler.HandleEmbeddingError(ctx, err)
+		}
 		return nil, fmt.Errorf("error embedding query: %w", err)
 	}
 
+	if handler != nil {
+		handler.HandleEmbeddingEnd(ctx, emb[:1])
+	}
 	return emb[0], nil
 }
 
 // EmbedDocuments creates one vector embedding for each of the texts.
 func (ei *EmbedderImpl) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
 	texts = MaybeRemoveNewLines(texts, ei.StripNewLines)
-	return BatchedEmbed(ctx, ei.client, texts, ei.BatchSize)
+	return batchedEmbed(ctx, ei.CallbacksHandler, ei.client, texts, ei.BatchSize)
 }
 
 func MaybeRemoveNewLines(texts []string, removeNewLines bool) []string {
@@ -96,18 +118,46 @@ func BatchTexts(texts []string, batchSize int) [][]string {
 }
 
 // BatchedEmbed creates embeddings for the given input texts, batching them
-// into batches of batchSize if needed.
+// into batches of batchSize if needed. The embedding events are sent to the
+// handlers of ctx, once for all the batches.
 func BatchedEmbed(ctx context.Context, embedder EmbedderClient, texts []string, batchSize int) ([][]float32, error) {
+	return batchedEmbed(ctx, nil, embedder, texts, batchSize)
+}
+
+func batchedEmbed(
+	ctx context.Context,
+	local callbacks.Handler,
+	embedder EmbedderClient,
+	texts []string,
+	batchSize int,
+) ([][]float32, error) {
+	ctx, _ = callbacks.StartRun(ctx, callbacks.RunTypeEmbedding, embedderName(embedder))
+	handler, _ := callbacks.Combine(ctx, local).(callbacks.EmbeddingHandler)
+	if handler != nil {
+		handler.HandleEmbeddingStart(ctx, texts)
+	}
+
 	batchedTexts := BatchTexts(texts, batchSize)
 
 	emb := make([][]float32, 0, len(texts))
 	for _, batch := range batchedTexts {
 		curBatchEmbeddings, err := embedder.CreateEmbedding(ctx, batch)
 		if err != nil {
+			if handler != nil {
+				handler.HandleEmbeddingError(ctx, err)
+			}
 			return nil, fmt.Errorf("error embedding batch: %w", err)
 		}
 		emb = append(emb, curBatchEmbeddings...)
 	}
 
+	if handler != nil {
+		handler.HandleEmbeddingEnd(ctx, emb)
+	}
 	return emb, nil
 }
+
+// embedderName is the run name of an embedder client, its type.
+func embedderName(client EmbedderClient) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", client), "*")
+}
@@ -1,5 +1,7 @@
 package embeddings
 
+import "github.com/mateors/llmg/callbacks"
+
 const (
 	defaultBatchSize     = 512
 	defaultStripNewLines = true
@@ -20,3 +22,11 @@ func WithBatchSize(batchSize int) Option {
 		p.BatchSize = batchSize
 	}
 }
+
+// WithCallbacksHandler is an option for setting the handler receiving the
+// embedding events.
+func WithCallbacksHandler(handler callbacks.Handler) Option {
+	return func(p *EmbedderImpl) {
+		p.CallbacksHandler = handler
+	}
+}
@@ -0,0 +1,340 @@
+package llms
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+)
+
+const _defaultBatchConcurrency = 4
+
+// ErrBatchKey is returned when a request of a checkpointed batch cannot be
+// keyed, e.g. because a call option holds a value that cannot be marshaled.
+var ErrBatchKey = errors.New("cannot key batch request")
+
+// BatchResult is the outcome of one request of a batch.
+type BatchResult struct {
+	// Index is the position of the request in the batch.
+	Index int
+	// Response is the response of the model, nil if the request failed.
+	Response *ContentResponse
+	// Err is the error of the request, if any.
+	Err error
+	// Resumed reports whether the response was loaded from the checkpoint
+	// instead of being generated.
+	Resumed bool
+}
+
+// BatchProgress reports how far a batch has come.
+type BatchProgress struct {
+	// Total is the number of requests in the batch.
+	Total int
+	// Completed is the number of requests that succeeded, including the ones
+	// resumed from the checkpoint.
+	Completed int
+	// Failed is the number of requests that failed.
+	Failed int
+}
+
+// BatchCheckpointer persists the responses of completed requests so that an
+// interrupted batch can be resumed. Requests are identified by a key derived
+// from their messages, the model and the call options, see BatchKey. Save is
+// called concurrently.
+type BatchCheckpointer interface {
+	Load(ctx context.Context) (map[string]*ContentResponse, error)
+	Save(ctx context.Context, key string, response *ContentResponse) error
+}
+
+type batchOptions struct {
+	concurrency  int
+	callOptions  []CallOption
+	progress     func(BatchProgress)
+	checkpointer BatchCheckpointer
+}
+
+// BatchOption is a function that configures a batch.
+type BatchOption func(*batchOptions)
+
+// WithBatchConcurrency sets how many requests are sent at the same time.
+// Defaults to 4.
+func WithBatchConcurrency(n int) BatchOption {
+	return func(o *batchOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithBatchCallOptions sets the call options used for every request.
+func WithBatchCallOptions(options ...CallOption) BatchOption {
+	return func(o *batchOptions) {
+		o.callOptions = append(o.callOptions, options...)
+	}
+}
+
+// WithBatchProgress sets a function called after every finished request.
+// Calls are serialized.
+func WithBatchProgress(fn func(BatchProgress)) BatchOption {
+	return func(o *batchOptions) {
+		o.progress = fn
+	}
+}
+
+// WithBatchCheckpointer sets where completed requests are persisted. Requests
+// found in the checkpoint are not sent again.
+func WithBatchCheckpointer(checkpointer BatchCheckpointer) BatchOption {
+	return func(o *batchOptions) {
+		o.checkpointer = checkpointer
+	}
+}
+
+// SinglePromptRequests turns string prompts into batch requests of a single
+// human message each, like GenerateFromSinglePrompt does.
+func SinglePromptRequests(prompts ...string) [][]MessageContent {
+	requests := make([][]MessageContent, 0, len(prompts))
+	for _, prompt := range prompts {
+		requests = append(requests, []MessageContent{TextParts(ChatMessageTypeHuman, prompt)})
+	}
+	return requests
+}
+
+// GenerateContentBatch sends many independent requests to a model with
+// bounded concurrency. The results are in the order of the requests. A
+// failing request does not abort the batch, its error is reported in its
+// result; once ctx is done, the requests not sent yet fail with the context's
+// error. The returned error is only non-nil if the checkpoint could not be
+// loaded or saved, or if a request could not be keyed, see BatchKey.
+func GenerateContentBatch(
+	ctx context.Context,
+	llm Model,
+	requests [][]MessageContent,
+	options ...BatchOption,
+) ([]BatchResult, error) {
+	opts := batchOptions{concurrency: _defaultBatchConcurrency}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	if opts.concurrency < 1 {
+		opts.concurrency = 1
+	}
+
+	results := make([]BatchResult, len(requests))
+	keys := make([]string, len(requests))
+	progress := BatchProgress{Total: len(requests)}
+
+	var checkpoint map[string]*ContentResponse
+	if opts.checkpointer != nil {
+		var err error
+		checkpoint, err = opts.checkpointer.Load(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("load checkpoint: %w", err)
+		}
+	}
+
+	todo := make([]int, 0, len(requests))
+	for i, messages := range requests {
+		results[i].Index = i
+		if opts.checkpointer != nil {
+			var err error
+			keys[i], err = BatchKey(llm, messages, opts.callOptions...)
+			if err != nil {
+				return nil, fmt.Errorf("request %d: %w", i, err)
+			}
+			if resp, ok := checkpoint[keys[i]]; ok {
+				results[i].Response = resp
+				results[i].Resumed = true
+				progress.Completed++
+				continue
+			}
+		}
+		todo = append(todo, i)
+	}
+	if progress.Completed > 0 && opts.progress != nil {
+		opts.progress(progress)
+	}
+
+	pending := make(chan int)
+	go func() {
+		defer close(pending)
+		for _, i := range todo {
+			pending <- i
+		}
+	}()
+
+	var (
+		mu       sync.Mutex
+		saveErrs []error
+		wg       sync.WaitGroup
+	)
+
+	for range opts.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				result := &results[i]
+				if err := ctx.Err(); err != nil {
+					result.Err = err
+				} else {
+					result.Response, result.Err = llm.GenerateContent(ctx, requests[i], opts.callOptions...)
+				}
+
+				var saveErr error
+				if result.Err == nil && opts.checkpointer != nil {
+					saveErr = opts.checkpointer.Save(ctx, keys[i], result.Response)
+				}
+
+				mu.Lock()
+				if saveErr != nil {
+					saveErrs = append(saveErrs, fmt.Errorf("save checkpoint of request %d: %w", i, saveErr))
+				}
+				if result.Err != nil {
+					progress.Failed++
+				} else {
+					progress.Completed++
+				}
+				if opts.progress != nil {
+					opts.progress(progress)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results, errors.Join(saveErrs...)
+}
+
+// BatchKey identifies a request in a checkpoint. It is a hash of the roles,
+// part types and contents of the messages, of the type of the model and of
+// the call options, so that a batch resumed with another model, e.g. set
+// with WithModel, or other options does not reuse the stale responses. A
+// model configured on the model value itself is not part of the key: use
+// another checkpoint when changing it. The options, including each Metadata
+// value, and the parts are keyed by their JSON encoding, so a provider
+// storing functions in Metadata must make them marshal to the settings they
+// apply. It fails with ErrBatchKey if something cannot be marshaled.
+func BatchKey(llm Model, messages []MessageContent, options ...CallOption) (string, error) {
+	var opts CallOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
+	metadata := opts.Metadata
+	opts.Metadata = nil
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return "", fmt.Errorf("%w: call options: %w", ErrBatchKey, err)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%T\x00%s\x00", llm, b)
+	for _, key := range slices.Sorted(maps.Keys(metadata)) {
+		b, err := json.Marshal(metadata[key])
+		if err != nil {
+			return "", fmt.Errorf("%w: metadata %q: %w", ErrBatchKey, key, err)
+		}
+		fmt.Fprintf(h, "%s\x00%s\x00", key, b)
+	}
+	h.Write([]byte{0xff})
+	for i, mc := range messages {
+		fmt.Fprintf(h, "%s\x00", mc.Role)
+		for _, p := range mc.Parts {
+			b, err := json.Marshal(p)
+			if err != nil {
+				return "", fmt.Errorf("%w: message %d: %T: %w", ErrBatchKey, i, p, err)
+			}
+			fmt.Fprintf(h, "%T\x00%s\x00", p, b)
+		}
+		h.Write([]byte{0xff})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FileCheckpointer is a BatchCheckpointer that appends completed requests to
+// a JSON Lines file. Numbers in GenerationInfo are read back as float64.
+type FileCheckpointer struct {
+	path string
+	mu   sync.Mutex
+}
+
+var _ BatchCheckpointer = (*FileCheckpointer)(nil)
+
+// NewFileCheckpointer creates a checkpointer writing to the file at path,
+// which is created on the first save.
+func NewFileCheckpointer(path string) *FileCheckpointer {
+	return &FileCheckpointer{path: path}
+}
+
+type checkpointEntry struct {
+	Key      string           `json:"key"`
+	Response *ContentResponse `json:"response"`
+}
+
+// Load reads the completed requests. A missing file is an empty checkpoint,
+// and a truncated last line, left by an interrupted write, is removed.
+func (c *FileCheckpointer) Load(_ context.Context) (map[string]*ContentResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, err := os.Open(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]*ContentResponse{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	responses := make(map[string]*ContentResponse)
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// A last line without a newline was not completely written, drop
+			// it so the next save starts on a fresh line.
+			if len(line) > 0 {
+				return responses, os.Truncate(c.path, offset)
+			}
+			return responses, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		offset += int64(len(line))
+
+		var entry checkpointEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.path, err)
+		}
+		responses[entry.Key] = entry.Response
+	}
+}
+
+// Save appends a completed request.
+func (c *FileCheckpointer) Save(_ context.Context, key string, response *ContentResponse) error {
+	b, err := json.Marshal(checkpointEntry{Key: key, Response: response})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, err := os.OpenFile(c.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,187 @@
+package llms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrCapabilitiesUnknown is returned when a model cannot report its
+	// capabilities.
+	ErrCapabilitiesUnknown = errors.New("model capabilities unknown")
+	// ErrCapabilityNotSupported is returned when a model lacks a capability
+	// that is required for an operation.
+	ErrCapabilityNotSupported = errors.New("capability not supported by model")
+)
+
+// Capability is a feature a model may or may not support.
+type Capability string
+
+const (
+	// CapabilityTools is native tool calling.
+	CapabilityTools Capability = "tools"
+	// CapabilityVision is image input.
+	CapabilityVision Capability = "vision"
+	// CapabilityJSONSchema is output constrained to a JSON schema.
+	CapabilityJSONSchema Capability = "json_schema"
+	// CapabilityThinking is a separate reasoning output before the answer.
+	CapabilityThinking Capability = "thinking"
+	// CapabilityEmbeddings is creating vector embeddings.
+	CapabilityEmbeddings Capability = "embeddings"
+)
+
+// Capabilities describes what a model supports.
+type Capabilities struct {
+	// Model is the name of the model the capabilities belong to.
+	Model string `json:"model"`
+	// Tools reports support for native tool calling.
+	Tools bool `json:"tools"`
+	// Vision reports support for image input.
+	Vision bool `json:"vision"`
+	// JSONSchema reports support for output constrained to a JSON schema.
+	JSONSchema bool `json:"json_schema"`
+	// Thinking reports support for separate reasoning output.
+	Thinking bool `json:"thinking"`
+	// Embeddings reports support for creating embeddings.
+	Embeddings bool `json:"embeddings"`
+	// ContextLength is the maximum number of tokens of prompt and completion
+	// combined. Zero means unknown.
+	ContextLength int `json:"context_length"`
+}
+
+// Supports reports whether c includes the given capability.
+func (c Capabilities) Supports(capability Capability) bool {
+	switch capability {
+	case CapabilityTools:
+		return c.Tools
+	case CapabilityVision:
+		return c.Vision
+	case CapabilityJSONSchema:
+		return c.JSONSchema
+	case CapabilityThinking:
+		return c.Thinking
+	case CapabilityEmbeddings:
+		return c.Embeddings
+	}
+	return false
+}
+
+// CapabilityReporter is implemented by models that can report their
+// capabilities.
+type CapabilityReporter interface {
+	Capabilities(ctx context.Context) (Capabilities, error)
+}
+
+// CapabilityRegistry maps model names to capabilities. Entries are discovered
+// from provider metadata and cached per source, the provider and server they
+// were discovered from, as the same model name may not have the same
+// capabilities everywhere. Static overrides are set per model name and take
+// precedence over anything discovered.
+type CapabilityRegistry struct {
+	mu         sync.RWMutex
+	discovered map[capabilityKey]Capabilities
+	overrides  map[string]Capabilities
+}
+
+type capabilityKey struct {
+	source, model string
+}
+
+// NewCapabilityRegistry creates an empty capability registry.
+func NewCapabilityRegistry() *CapabilityRegistry {
+	return &CapabilityRegistry{
+		discovered: make(map[capabilityKey]Capabilities),
+		overrides:  make(map[string]Capabilities),
+	}
+}
+
+// DefaultCapabilityRegistry is the registry used by the providers.
+var DefaultCapabilityRegistry = NewCapabilityRegistry() //nolint:gochecknoglobals
+
+// RegisterCapabilities sets a static override for the model in the default
+// registry.
+func RegisterCapabilities(model string, capabilities Capabilities) {
+	DefaultCapabilityRegistry.Override(model, capabilities)
+}
+
+// Override sets the capabilities of a model, taking precedence over the
+// capabilities discovered from provider metadata.
+func (r *CapabilityRegistry) Override(model string, capabilities Capabilities) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	capabilities.Model = model
+	r.overrides[model] = capabilities
+}
+
+// Forget drops the overridden capabilities of a model and the ones cached for
+// it from every source.
+func (r *CapabilityRegistry) Forget(model string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.discovered {
+		if key.model == model {
+			delete(r.discovered, key)
+		}
+	}
+	delete(r.overrides, model)
+}
+
+// Overridden returns the static override of a model, if any.
+func (r *CapabilityRegistry) Overridden(model string) (Capabilities, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.overrides[model]
+	return c, ok
+}
+
+// Lookup returns the known capabilities of a model served by source without
+// discovering them.
+func (r *CapabilityRegistry) Lookup(source, model string) (Capabilities, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if c, ok := r.overrides[model]; ok {
+		return c, true
+	}
+	c, ok := r.discovered[capabilityKey{source, model}]
+	return c, ok
+}
+
+// Resolve returns the capabilities of a model served by source, calling
+// discover and caching its result if the model is not known yet. The source
+// identifies where the model is served, e.g. the provider and server URL.
+func (r *CapabilityRegistry) Resolve(
+	ctx context.Context,
+	source, model string,
+	discover func(ctx context.Context) (Capabilities, error),
+) (Capabilities, error) {
+	if c, ok := r.Lookup(source, model); ok {
+		return c, nil
+	}
+
+	c, err := discover(ctx)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	c.Model = model
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// An override may have been registered while discovering.
+	if o, ok := r.overrides[model]; ok {
+		return o, nil
+	}
+	r.discovered[capabilityKey{source, model}] = c
+	return c, nil
+}
+
+// GetCapabilities returns the capabilities of a model. It returns
+// ErrCapabilitiesUnknown if the model does not implement CapabilityReporter.
+func GetCapabilities(ctx context.Context, m Model) (Capabilities, error) {
+	reporter, ok := m.(CapabilityReporter)
+	if !ok {
+		return Capabilities{}, fmt.Errorf("%w: %T does not report capabilities", ErrCapabilitiesUnknown, m)
+	}
+	return reporter.Capabilities(ctx)
+}
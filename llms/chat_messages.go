@@ -142,3 +142,23 @@ func getMessageRole(m ChatMessage, humanPrefix, aiPrefix string) (string, error)
 	}
 	return role, nil
 }
+
+// ChatMessagesToContent converts chat messages to the messages of a
+// GenerateContent call. The tool calls of AI messages become ToolCall parts;
+// the role of generic messages is not kept.
+func ChatMessagesToContent(messages []ChatMessage) []MessageContent {
+	result := make([]MessageContent, 0, len(messages))
+	for _, m := range messages {
+		mc := MessageContent{Role: m.GetType()}
+		if content := m.GetContent(); content != "" {
+			mc.Parts = append(mc.Parts, TextContent{Text: content})
+		}
+		if ai, ok := m.(AIChatMessage); ok {
+			for _, tc := range ai.ToolCalls {
+				mc.Parts = append(mc.Parts, tc)
+			}
+		}
+		result = append(result, mc)
+	}
+	return result
+}
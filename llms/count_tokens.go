@@ -0,0 +1,55 @@
+package llms
+
+import (
+	"log"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+const (
+	_tokenApproximation   = 4
+	_defaultTokenEncoding = "cl100k_base"
+)
+
+type encoding struct {
+	tk  *tiktoken.Tiktoken
+	err error
+}
+
+var (
+	encodingsMu sync.Mutex                  //nolint:gochecknoglobals
+	encodings   = make(map[string]encoding) //nolint:gochecknoglobals
+)
+
+// CountTokens returns the number of tokens in text. The tokenizer of the
+// model is used if tiktoken knows it, cl100k_base otherwise, which is a fair
+// estimate for most open models. If no tokenizer is available, the count is
+// approximated from the number of characters.
+func CountTokens(model, text string) int {
+	e, err := getEncoding(model)
+	if err != nil {
+		return len([]rune(text)) / _tokenApproximation
+	}
+	return len(e.Encode(text, nil, nil))
+}
+
+func getEncoding(model string) (*tiktoken.Tiktoken, error) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+
+	// Failures are remembered too, loading an encoding may need the network.
+	if e, ok := encodings[model]; ok {
+		return e.tk, e.err
+	}
+
+	tk, err := tiktoken.EncodingForModel(model)
+	if err != nil {
+		tk, err = tiktoken.GetEncoding(_defaultTokenEncoding)
+	}
+	if err != nil {
+		log.Printf("[WARN] failed to load a tokenizer, falling back to an approximate token count: %v", err)
+	}
+	encodings[model] = encoding{tk: tk, err: err}
+	return tk, err
+}
@@ -65,6 +65,35 @@ type ContentChoice struct {
 
 	// This field is only used with the deepseek-reasoner model and represents the reasoning contents of the assistant message before the final answer.
 	ReasoningContent string
+
+	// LogProbs holds the log probability of each generated token. It is only
+	// populated when requested with WithLogProbs or WithTopLogProbs and the
+	// model supports it.
+	LogProbs []TokenLogProb
+}
+
+// TokenLogProb is the log probability of a generated token.
+type TokenLogProb struct {
+	// Token is the text of the token.
+	Token string `json:"token"`
+	// LogProb is the natural log of the probability of the token.
+	LogProb float64 `json:"logprob"`
+	// Bytes is the UTF-8 encoding of the token, useful when a token holds
+	// only part of a multi-byte character.
+	Bytes []byte `json:"bytes,omitempty"`
+	// TopLogProbs holds the most likely tokens at this position, most likely
+	// first. It is only populated when requested with WithTopLogProbs.
+	TopLogProbs []TopLogProb `json:"top_logprobs,omitempty"`
+}
+
+// TopLogProb is one of the most likely tokens at a position.
+type TopLogProb struct {
+	// Token is the text of the token.
+	Token string `json:"token"`
+	// LogProb is the natural log of the probability of the token.
+	LogProb float64 `json:"logprob"`
+	// Bytes is the UTF-8 encoding of the token.
+	Bytes []byte `json:"bytes,omitempty"`
 }
 
 // FunctionCall is the name and arguments of a function call.
@@ -87,6 +116,18 @@ type ToolCall struct {
 
 func (ToolCall) isPart() {}
 
+// ToolCallResponse is the response returned by a tool call.
+type ToolCallResponse struct {
+	// ToolCallID is the ID of the tool call this response is for.
+	ToolCallID string `json:"tool_call_id"`
+	// Name is the name of the tool that was called.
+	Name string `json:"name"`
+	// Content is the textual content of the response.
+	Content string `json:"content"`
+}
+
+func (ToolCallResponse) isPart() {}
+
 // TextParts is a helper function to create a MessageContent with a role and a
 // list of text parts.
 func TextParts(role ChatMessageType, parts ...string) MessageContent {
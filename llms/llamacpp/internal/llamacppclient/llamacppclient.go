@@ -0,0 +1,228 @@
+package llamacppclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+)
+
+const defaultBaseURL = "http://127.0.0.1:8080"
+
+type Client struct {
+	base       *url.URL
+	httpClient *http.Client
+	apiKey     string
+}
+
+func NewClient(lurl *url.URL, lhttp *http.Client, apiKey string) (*Client, error) {
+	if lurl == nil {
+		var err error
+		lurl, err = url.Parse(defaultBaseURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if lhttp == nil {
+		lhttp = &http.Client{
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+			},
+		}
+	}
+
+	return &Client{
+		base:       lurl,
+		httpClient: lhttp,
+		apiKey:     apiKey,
+	}, nil
+}
+
+func checkError(resp *http.Response, body []byte) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	var errResp errorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error == nil {
+		// Use the full body as the message if we fail to decode a response.
+		return StatusError{StatusCode: resp.StatusCode, ErrorMessage: strings.TrimSpace(string(body))}
+	}
+	if errResp.Error.StatusCode == 0 {
+		errResp.Error.StatusCode = resp.StatusCode
+	}
+	return *errResp.Error
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, reqData any) (*http.Request, error) {
+	var reqBody io.Reader
+	if reqData != nil {
+		data, err := json.Marshal(reqData)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	requestURL := c.base.JoinPath(path)
+	request, err := http.NewRequestWithContext(ctx, method, requestURL.String(), reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent",
+		fmt.Sprintf("llmg (%s %s) Go/%s", runtime.GOARCH, runtime.GOOS, runtime.Version()))
+	if c.apiKey != "" {
+		request.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	return request, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, reqData, respData any) error {
+	request, err := c.newRequest(ctx, method, path, reqData)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	respObj, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer respObj.Body.Close()
+
+	respBody, err := io.ReadAll(respObj.Body)
+	if err != nil {
+		return err
+	}
+
+	if err := checkError(respObj, respBody); err != nil {
+		return err
+	}
+
+	if len(respBody) > 0 && respData != nil {
+		if err := json.Unmarshal(respBody, respData); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const maxBufferSize = 512 * 1000
+
+// stream reads the server-sent events of a streaming endpoint and calls fn
+// with the payload of every "data:" line.
+func (c *Client) stream(ctx context.Context, method, path string, reqData any, fn func([]byte) error) error {
+	request, err := c.newRequest(ctx, method, path, reqData)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "text/event-stream")
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return err
+		}
+		return checkError(response, body)
+	}
+
+	scanner := bufio.NewScanner(response.Body)
+	// increase the buffer size to avoid running out of space
+	scanBuf := make([]byte, 0, maxBufferSize)
+	scanner.Buffer(scanBuf, maxBufferSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		switch {
+		case bytes.HasPrefix(line, []byte("data:")):
+			data := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+			if len(data) == 0 || bytes.Equal(data, []byte("[DONE]")) {
+				continue
+			}
+			var errResp errorResponse
+			if err := json.Unmarshal(data, &errResp); err == nil && errResp.Error != nil {
+				return *errResp.Error
+			}
+			if err := fn(data); err != nil {
+				return err
+			}
+		case bytes.HasPrefix(line, []byte("error:")):
+			data := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("error:")))
+			var statusErr StatusError
+			if err := json.Unmarshal(data, &statusErr); err != nil {
+				return fmt.Errorf("%s", data) //nolint
+			}
+			return statusErr
+		}
+	}
+
+	return scanner.Err()
+}
+
+type CompletionResponseFunc func(CompletionResponse) error
+
+// Completion calls the /completion endpoint. fn is called once for a
+// non-streaming request and once per chunk for a streaming one.
+func (c *Client) Completion(ctx context.Context, req *CompletionRequest, fn CompletionResponseFunc) error {
+	if !req.Stream {
+		var resp CompletionResponse
+		if err := c.do(ctx, http.MethodPost, "/completion", req, &resp); err != nil {
+			return err
+		}
+		return fn(resp)
+	}
+
+	return c.stream(ctx, http.MethodPost, "/completion", req, func(bts []byte) error {
+		var resp CompletionResponse
+		if err := json.Unmarshal(bts, &resp); err != nil {
+			return err
+		}
+		return fn(resp)
+	})
+}
+
+// ApplyTemplate formats chat messages into a prompt with the chat template of
+// the loaded model.
+func (c *Client) ApplyTemplate(ctx context.Context, req *ApplyTemplateRequest) (*ApplyTemplateResponse, error) {
+	resp := &ApplyTemplateResponse{}
+	if err := c.do(ctx, http.MethodPost, "/apply-template", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Props returns the properties of the server and the loaded model.
+func (c *Client) Props(ctx context.Context) (*PropsResponse, error) {
+	resp := &PropsResponse{}
+	if err := c.do(ctx, http.MethodGet, "/props", nil, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// URL returns the base URL of the server.
+func (c *Client) URL() string {
+	return c.base.String()
+}
+
+func (c *Client) CreateEmbedding(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	resp := &EmbeddingResponse{}
+	if err := c.do(ctx, http.MethodPost, "/embedding", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
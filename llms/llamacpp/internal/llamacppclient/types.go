@@ -0,0 +1,224 @@
+package llamacppclient
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// StatusError is an error returned by the llama.cpp server.
+type StatusError struct {
+	StatusCode   int    `json:"code"`
+	Type         string `json:"type"`
+	ErrorMessage string `json:"message"`
+}
+
+func (e StatusError) Error() string {
+	switch {
+	case e.Type != "" && e.ErrorMessage != "":
+		return fmt.Sprintf("%s: %s", e.Type, e.ErrorMessage)
+	case e.ErrorMessage != "":
+		return e.ErrorMessage
+	default:
+		return fmt.Sprintf("llama.cpp server returned status %d", e.StatusCode)
+	}
+}
+
+type errorResponse struct {
+	Error *StatusError `json:"error,omitempty"`
+}
+
+// Message is a chat message sent to the /apply-template endpoint.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ApplyTemplateRequest struct {
+	Messages []Message `json:"messages"`
+}
+
+type ApplyTemplateResponse struct {
+	Prompt string `json:"prompt"`
+}
+
+type CompletionRequest struct {
+	Prompt           string   `json:"prompt"`
+	Stream           bool     `json:"stream"`
+	NPredict         int      `json:"n_predict,omitempty"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopK             int      `json:"top_k,omitempty"`
+	TopP             float64  `json:"top_p,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	RepeatPenalty    float64  `json:"repeat_penalty,omitempty"`
+	FrequencyPenalty float64  `json:"frequency_penalty,omitempty"`
+	PresencePenalty  float64  `json:"presence_penalty,omitempty"`
+	Grammar          string   `json:"grammar,omitempty"`
+	JSONSchema       any      `json:"json_schema,omitempty"`
+	NProbs           int      `json:"n_probs,omitempty"`
+	IDSlot           *int     `json:"id_slot,omitempty"`
+	CachePrompt      *bool    `json:"cache_prompt,omitempty"`
+}
+
+// TopTokenProbability is one of the most likely tokens at a position.
+type TopTokenProbability struct {
+	ID      int     `json:"id"`
+	Token   string  `json:"token"`
+	LogProb float64 `json:"logprob"`
+	Bytes   []byte  `json:"bytes"`
+}
+
+// TokenProbability is the probability information of a generated token. Older
+// servers only fill Content and Probs.
+type TokenProbability struct {
+	ID          int                   `json:"id"`
+	Token       string                `json:"token"`
+	LogProb     float64               `json:"logprob"`
+	Bytes       []byte                `json:"bytes"`
+	TopLogProbs []TopTokenProbability `json:"top_logprobs,omitempty"`
+
+	Content string `json:"content,omitempty"`
+	Probs   []struct {
+		TokStr string  `json:"tok_str"`
+		Prob   float64 `json:"prob"`
+	} `json:"probs,omitempty"`
+}
+
+// UnmarshalJSON decodes bytes given either as a byte array or a base64 string.
+func (t *TopTokenProbability) UnmarshalJSON(data []byte) error {
+	type alias TopTokenProbability
+	var raw struct {
+		alias
+		Bytes json.RawMessage `json:"bytes"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*t = TopTokenProbability(raw.alias)
+	t.Bytes = decodeBytes(raw.Bytes)
+	return nil
+}
+
+// UnmarshalJSON decodes bytes given either as a byte array or a base64 string.
+func (t *TokenProbability) UnmarshalJSON(data []byte) error {
+	type alias TokenProbability
+	var raw struct {
+		alias
+		Bytes json.RawMessage `json:"bytes"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*t = TokenProbability(raw.alias)
+	t.Bytes = decodeBytes(raw.Bytes)
+	return nil
+}
+
+func decodeBytes(raw json.RawMessage) []byte {
+	if len(raw) == 0 {
+		return nil
+	}
+	var ints []int
+	if err := json.Unmarshal(raw, &ints); err == nil {
+		b := make([]byte, len(ints))
+		for i, v := range ints {
+			b[i] = byte(v)
+		}
+		return b
+	}
+	var b []byte
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b
+	}
+	return nil
+}
+
+type Timings struct {
+	PromptN             int     `json:"prompt_n"`
+	PromptMS            float64 `json:"prompt_ms"`
+	PromptPerSecond     float64 `json:"prompt_per_second"`
+	PredictedN          int     `json:"predicted_n"`
+	PredictedMS         float64 `json:"predicted_ms"`
+	PredictedPerSecond  float64 `json:"predicted_per_second"`
+	PredictedPerTokenMS float64 `json:"predicted_per_token_ms"`
+}
+
+type CompletionResponse struct {
+	Content                 string             `json:"content"`
+	Stop                    bool               `json:"stop"`
+	IDSlot                  int                `json:"id_slot"`
+	Model                   string             `json:"model"`
+	TokensPredicted         int                `json:"tokens_predicted"`
+	TokensEvaluated         int                `json:"tokens_evaluated"`
+	TokensCached            int                `json:"tokens_cached"`
+	StoppedEOS              bool               `json:"stopped_eos"`
+	StoppedWord             bool               `json:"stopped_word"`
+	StoppedLimit            bool               `json:"stopped_limit"`
+	StoppingWord            string             `json:"stopping_word"`
+	Truncated               bool               `json:"truncated"`
+	CompletionProbabilities []TokenProbability `json:"completion_probabilities,omitempty"`
+	Timings                 *Timings           `json:"timings,omitempty"`
+}
+
+type EmbeddingRequest struct {
+	Content string `json:"content"`
+}
+
+// EmbeddingResponse is the response of the /embedding endpoint. Older servers
+// return a single vector, newer ones a list of results with one vector per
+// pooled sequence.
+type EmbeddingResponse struct {
+	Embedding []float32 `json:"embedding"`
+}
+
+// UnmarshalJSON accepts both the legacy and the current response formats.
+func (r *EmbeddingResponse) UnmarshalJSON(data []byte) error {
+	var legacy struct {
+		Embedding json.RawMessage `json:"embedding"`
+	}
+	if err := json.Unmarshal(data, &legacy); err == nil && legacy.Embedding != nil {
+		return r.decodeVector(legacy.Embedding)
+	}
+
+	var results []struct {
+		Embedding json.RawMessage `json:"embedding"`
+	}
+	if err := json.Unmarshal(data, &results); err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	return r.decodeVector(results[0].Embedding)
+}
+
+func (r *EmbeddingResponse) decodeVector(raw json.RawMessage) error {
+	if err := json.Unmarshal(raw, &r.Embedding); err == nil {
+		return nil
+	}
+	var rows [][]float32
+	if err := json.Unmarshal(raw, &rows); err != nil {
+		return err
+	}
+	if len(rows) > 0 {
+		r.Embedding = rows[0]
+	}
+	return nil
+}
+
+type GenerationSettings struct {
+	NCtx int `json:"n_ctx"`
+}
+
+type Modalities struct {
+	Vision bool `json:"vision"`
+	Audio  bool `json:"audio"`
+}
+
+type PropsResponse struct {
+	DefaultGenerationSettings GenerationSettings `json:"default_generation_settings"`
+	TotalSlots                int                `json:"total_slots"`
+	ModelPath                 string             `json:"model_path"`
+	ChatTemplate              string             `json:"chat_template"`
+	Modalities                Modalities         `json:"modalities"`
+}
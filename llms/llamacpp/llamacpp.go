@@ -0,0 +1,366 @@
+package llamacpp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/mateors/llmg/callbacks"
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/llms/llamacpp/internal/llamacppclient"
+)
+
+var (
+	ErrEmptyResponse       = errors.New("no response")
+	ErrIncompleteEmbedding = errors.New("not all input got embedded")
+	ErrInvalidCallOption   = errors.New("invalid call option")
+)
+
+// LLM is a llama.cpp server LLM implementation using the native /completion
+// and /embedding endpoints.
+type LLM struct {
+	CallbacksHandler callbacks.Handler
+	client           *llamacppclient.Client
+	options          options
+}
+
+var (
+	_ llms.Model              = (*LLM)(nil)
+	_ llms.CapabilityReporter = (*LLM)(nil)
+)
+
+// New creates a new llama.cpp LLM implementation.
+func New(opts ...Option) (*LLM, error) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	client, err := llamacppclient.NewClient(o.serverURL, o.httpClient, o.apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LLM{client: client, options: o}, nil
+}
+
+// GenerateContent implements the Model interface.
+func (l *LLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) { // nolint: lll
+	ctx, _ = callbacks.StartRun(ctx, callbacks.RunTypeLLM, l.modelName())
+	handler := callbacks.Combine(ctx, l.CallbacksHandler)
+	if handler != nil {
+		handler.HandleLLMGenerateContentStart(ctx, messages)
+	}
+
+	opts := llms.CallOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	// Completion requests do not carry tools, fail instead of silently
+	// dropping them.
+	if len(opts.Tools) > 0 {
+		err := fmt.Errorf("%w: llamacpp client does not send %s, wrap the model with toolshim.New",
+			llms.ErrCapabilityNotSupported, llms.CapabilityTools)
+		return nil, handleError(ctx, handler, err)
+	}
+
+	prompt, err := l.formatPrompt(ctx, messages)
+	if err != nil {
+		return nil, handleError(ctx, handler, err)
+	}
+
+	req, err := l.makeCompletionRequest(prompt, opts)
+	if err != nil {
+		return nil, handleError(ctx, handler, err)
+	}
+
+	streamedResponse := ""
+	var resp llamacppclient.CompletionResponse
+	var probabilities []llamacppclient.TokenProbability
+
+	fn := func(response llamacppclient.CompletionResponse) error {
+		if opts.StreamingFunc != nil && response.Content != "" {
+			chunk := []byte(response.Content)
+			if handler != nil {
+				handler.HandleStreamingFunc(ctx, chunk)
+			}
+			if err := opts.StreamingFunc(ctx, chunk); err != nil {
+				return err
+			}
+		}
+		streamedResponse += response.Content
+		probabilities = append(probabilities, response.CompletionProbabilities...)
+		if !req.Stream || response.Stop {
+			resp = response
+			resp.Content = streamedResponse
+			resp.CompletionProbabilities = probabilities
+		}
+		return nil
+	}
+
+	if err := l.client.Completion(ctx, req, fn); err != nil {
+		return nil, handleError(ctx, handler, err)
+	}
+
+	generationInfo := map[string]any{
+		"CompletionTokens": resp.TokensPredicted,
+		"PromptTokens":     resp.TokensEvaluated,
+		"TotalTokens":      resp.TokensPredicted + resp.TokensEvaluated,
+		"CachedTokens":     resp.TokensCached,
+		"SlotID":           resp.IDSlot,
+	}
+
+	// Keep the alternatives asked for with WithTopLogProbs, or with n_probs
+	// if it was set directly, whichever is more.
+	topLogProbs := req.NProbs
+	if opts.LogProbs {
+		topLogProbs = max(opts.TopLogProbs, req.NProbs)
+	}
+
+	choices := []*llms.ContentChoice{
+		{
+			Content:        resp.Content,
+			StopReason:     stopReason(resp),
+			GenerationInfo: generationInfo,
+			LogProbs:       makeLogProbs(resp.CompletionProbabilities, topLogProbs),
+		},
+	}
+
+	response := &llms.ContentResponse{Choices: choices}
+
+	if handler != nil {
+		handler.HandleLLMGenerateContentEnd(ctx, response)
+	}
+
+	return response, nil
+}
+
+func handleError(ctx context.Context, handler callbacks.Handler, err error) error {
+	if handler != nil {
+		handler.HandleLLMError(ctx, err)
+	}
+	return err
+}
+
+// modelName returns the name set with WithModel, or the server URL.
+func (l *LLM) modelName() string {
+	if l.options.model != "" {
+		return l.options.model
+	}
+	return l.client.URL()
+}
+
+// CreateEmbedding embeds each of the input texts with the /embedding
+// endpoint. The server must be started with embeddings enabled.
+func (l *LLM) CreateEmbedding(ctx context.Context, inputTexts []string) ([][]float32, error) {
+	embeddings := [][]float32{}
+
+	for _, input := range inputTexts {
+		embedding, err := l.client.CreateEmbedding(ctx, &llamacppclient.EmbeddingRequest{Content: input})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(embedding.Embedding) == 0 {
+			return nil, ErrEmptyResponse
+		}
+
+		embeddings = append(embeddings, embedding.Embedding)
+	}
+
+	if len(inputTexts) != len(embeddings) {
+		return embeddings, ErrIncompleteEmbedding
+	}
+
+	return embeddings, nil
+}
+
+// Capabilities implements llms.CapabilityReporter. The capabilities are
+// discovered with /props and cached in llms.DefaultCapabilityRegistry for the
+// server, where they can also be overridden. Native tool calling is not
+// available on the /completion endpoint, and whether embeddings are enabled is
+// not reported by the server, so they are assumed to be.
+func (l *LLM) Capabilities(ctx context.Context) (llms.Capabilities, error) {
+	source := "llamacpp " + l.client.URL()
+	return llms.DefaultCapabilityRegistry.Resolve(ctx, source, l.modelName(), func(ctx context.Context) (llms.Capabilities, error) {
+		props, err := l.client.Props(ctx)
+		if err != nil {
+			return llms.Capabilities{}, fmt.Errorf("get server properties: %w", err)
+		}
+		return llms.Capabilities{
+			Vision:        props.Modalities.Vision,
+			JSONSchema:    true,
+			Embeddings:    true,
+			ContextLength: props.DefaultGenerationSettings.NCtx,
+		}, nil
+	})
+}
+
+// formatPrompt turns the messages into a single prompt, either with the
+// configured formatter or with the chat template of the loaded model.
+func (l *LLM) formatPrompt(ctx context.Context, messages []llms.MessageContent) (string, error) {
+	if l.options.promptFormatter != nil {
+		return l.options.promptFormatter(messages)
+	}
+
+	msgs := make([]llamacppclient.Message, 0, len(messages))
+	for _, mc := range messages {
+		text, err := textOf(mc)
+		if err != nil {
+			return "", err
+		}
+		msgs = append(msgs, llamacppclient.Message{Role: typeToRole(mc.Role), Content: text})
+	}
+
+	resp, err := l.client.ApplyTemplate(ctx, &llamacppclient.ApplyTemplateRequest{Messages: msgs})
+	if err != nil {
+		return "", err
+	}
+	return resp.Prompt, nil
+}
+
+// textOf returns the text of a message, which must consist of a single text part.
+func textOf(mc llms.MessageContent) (string, error) {
+	var text string
+	foundText := false
+	for _, p := range mc.Parts {
+		pt, ok := p.(llms.TextContent)
+		if !ok {
+			return "", errors.New("only support Text parts right now") //nolint:goerr113
+		}
+		if foundText {
+			return "", errors.New("expecting a single Text content") //nolint:goerr113
+		}
+		foundText = true
+		text = pt.Text
+	}
+	return text, nil
+}
+
+func (l *LLM) makeCompletionRequest(prompt string, opts llms.CallOptions) (*llamacppclient.CompletionRequest, error) {
+	req := &llamacppclient.CompletionRequest{
+		Prompt:           prompt,
+		Stream:           opts.StreamingFunc != nil,
+		NPredict:         opts.MaxTokens,
+		TopK:             opts.TopK,
+		TopP:             opts.TopP,
+		Stop:             opts.StopWords,
+		RepeatPenalty:    opts.RepetitionPenalty,
+		FrequencyPenalty: opts.FrequencyPenalty,
+		PresencePenalty:  opts.PresencePenalty,
+		CachePrompt:      l.options.cachePrompt,
+	}
+	if opts.Temperature != 0 {
+		req.Temperature = &opts.Temperature
+	}
+	if opts.Seed != 0 {
+		req.Seed = &opts.Seed
+	}
+
+	for key, value := range opts.Metadata {
+		var ok bool
+		switch key {
+		case metadataGrammar:
+			req.Grammar, ok = value.(string)
+		case metadataJSONSchema:
+			req.JSONSchema, ok = value, value != nil
+		case metadataNProbs:
+			req.NProbs, ok = value.(int)
+		case metadataSlotID:
+			var id int
+			id, ok = value.(int)
+			req.IDSlot = &id
+		case metadataCachePrompt:
+			var cache bool
+			cache, ok = value.(bool)
+			req.CachePrompt = &cache
+		default:
+			continue
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidCallOption, key, value)
+		}
+	}
+
+	if opts.LogProbs && req.NProbs == 0 {
+		req.NProbs = max(opts.TopLogProbs, 1)
+	}
+
+	if opts.JSONMode && req.Grammar == "" && req.JSONSchema == nil {
+		req.JSONSchema = map[string]any{"type": "object"}
+	}
+
+	return req, nil
+}
+
+// makeLogProbs converts the token probabilities returned by the server,
+// keeping at most topN alternatives per token.
+func makeLogProbs(probabilities []llamacppclient.TokenProbability, topN int) []llms.TokenLogProb {
+	if len(probabilities) == 0 {
+		return nil
+	}
+
+	logProbs := make([]llms.TokenLogProb, 0, len(probabilities))
+	for _, p := range probabilities {
+		lp := llms.TokenLogProb{Token: p.Token, LogProb: p.LogProb, Bytes: p.Bytes}
+		for _, top := range p.TopLogProbs {
+			lp.TopLogProbs = append(lp.TopLogProbs, llms.TopLogProb{Token: top.Token, LogProb: top.LogProb, Bytes: top.Bytes})
+		}
+
+		// Older servers report probabilities instead of log probabilities.
+		if p.Token == "" && p.Content != "" {
+			lp = llms.TokenLogProb{Token: p.Content, LogProb: math.Inf(-1), Bytes: []byte(p.Content)}
+			for _, prob := range p.Probs {
+				if prob.TokStr == p.Content {
+					lp.LogProb = math.Log(prob.Prob)
+				}
+				lp.TopLogProbs = append(lp.TopLogProbs, llms.TopLogProb{
+					Token:   prob.TokStr,
+					LogProb: math.Log(prob.Prob),
+					Bytes:   []byte(prob.TokStr),
+				})
+			}
+		}
+
+		if len(lp.TopLogProbs) > topN {
+			lp.TopLogProbs = lp.TopLogProbs[:topN]
+		}
+		if len(lp.TopLogProbs) == 0 {
+			lp.TopLogProbs = nil
+		}
+		logProbs = append(logProbs, lp)
+	}
+	return logProbs
+}
+
+func stopReason(resp llamacppclient.CompletionResponse) string {
+	switch {
+	case resp.StoppedLimit:
+		return "length"
+	case resp.StoppedWord:
+		return "stop_word"
+	case resp.StoppedEOS:
+		return "stop"
+	}
+	return ""
+}
+
+func typeToRole(typ llms.ChatMessageType) string {
+	switch typ {
+	case llms.ChatMessageTypeSystem:
+		return "system"
+	case llms.ChatMessageTypeAI:
+		return "assistant"
+	case llms.ChatMessageTypeHuman:
+		fallthrough
+	case llms.ChatMessageTypeGeneric:
+		return "user"
+	case llms.ChatMessageTypeFunction:
+		return "function"
+	case llms.ChatMessageTypeTool:
+		return "tool"
+	}
+	return ""
+}
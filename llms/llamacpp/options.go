@@ -0,0 +1,129 @@
+package llamacpp
+
+import (
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+
+	"github.com/mateors/llmg/llms"
+)
+
+type options struct {
+	serverURL       *url.URL
+	httpClient      *http.Client
+	apiKey          string
+	promptFormatter PromptFormatter
+	cachePrompt     *bool
+	model           string
+}
+
+// PromptFormatter turns chat messages into the raw prompt sent to the
+// /completion endpoint.
+type PromptFormatter func(messages []llms.MessageContent) (string, error)
+
+type Option func(*options)
+
+// WithServerURL Set the URL of the llama.cpp server to use.
+func WithServerURL(rawURL string) Option {
+	return func(opts *options) {
+		var err error
+		opts.serverURL, err = url.Parse(rawURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// WithModel Set the name the loaded model is known by, which is the name its
+// capabilities are cached and overridden under in
+// llms.DefaultCapabilityRegistry. It defaults to the server URL, as a
+// llama.cpp server serves a single model.
+func WithModel(model string) Option {
+	return func(opts *options) {
+		opts.model = model
+	}
+}
+
+// WithHTTPClient Set the HTTP client used to talk to the server.
+func WithHTTPClient(client *http.Client) Option {
+	return func(opts *options) {
+		opts.httpClient = client
+	}
+}
+
+// WithAPIKey Set the API key the server was started with (--api-key).
+func WithAPIKey(apiKey string) Option {
+	return func(opts *options) {
+		opts.apiKey = apiKey
+	}
+}
+
+// WithPromptFormatter Set how messages are turned into a prompt. By default
+// the server's /apply-template endpoint is used, which applies the chat
+// template of the loaded model.
+func WithPromptFormatter(formatter PromptFormatter) Option {
+	return func(opts *options) {
+		opts.promptFormatter = formatter
+	}
+}
+
+// WithDefaultCachePrompt Set whether the server should reuse the KV cache of
+// a previous request for the common prompt prefix, unless overridden per call
+// with WithCachePrompt.
+func WithDefaultCachePrompt(cache bool) Option {
+	return func(opts *options) {
+		opts.cachePrompt = &cache
+	}
+}
+
+// Keys of the llama.cpp specific call options stored in llms.CallOptions.Metadata.
+const (
+	metadataGrammar     = "llamacpp.grammar"
+	metadataJSONSchema  = "llamacpp.json_schema"
+	metadataNProbs      = "llamacpp.n_probs"
+	metadataSlotID      = "llamacpp.id_slot"
+	metadataCachePrompt = "llamacpp.cache_prompt"
+)
+
+// WithGrammar constrains generation with a GBNF grammar.
+func WithGrammar(grammar string) llms.CallOption {
+	return withMetadata(metadataGrammar, grammar)
+}
+
+// WithJSONSchema constrains generation to JSON matching the given JSON
+// schema. The server converts the schema into a grammar. The schema can be
+// anything that marshals to a JSON schema object.
+func WithJSONSchema(schema any) llms.CallOption {
+	return withMetadata(metadataJSONSchema, schema)
+}
+
+// WithNProbs asks the server to return the probabilities of the n most likely
+// tokens for each generated token. They are returned in
+// llms.ContentChoice.LogProbs, as with llms.WithTopLogProbs.
+func WithNProbs(n int) llms.CallOption {
+	return withMetadata(metadataNProbs, n)
+}
+
+// WithSlotID pins the request to a server slot, which keeps its KV cache
+// between requests.
+func WithSlotID(id int) llms.CallOption {
+	return withMetadata(metadataSlotID, id)
+}
+
+// WithCachePrompt sets whether the server should reuse the KV cache of a
+// previous request for the common prompt prefix.
+func WithCachePrompt(cache bool) llms.CallOption {
+	return withMetadata(metadataCachePrompt, cache)
+}
+
+// withMetadata sets a metadata key without mutating a map the caller may
+// share between calls.
+func withMetadata(key string, value any) llms.CallOption {
+	return func(o *llms.CallOptions) {
+		metadata := make(map[string]any, len(o.Metadata)+1)
+		maps.Copy(metadata, o.Metadata)
+		metadata[key] = value
+		o.Metadata = metadata
+	}
+}
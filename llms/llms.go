@@ -5,6 +5,9 @@ import (
 	"errors"
 )
 
+// ErrEmptyResponse is returned when the model returns no choice.
+var ErrEmptyResponse = errors.New("empty response from model")
+
 //type LLM = Model
 
 type Model interface {
@@ -29,7 +32,7 @@ func GenerateFromSinglePrompt(ctx context.Context, llm Model, prompt string, opt
 
 	choices := resp.Choices
 	if len(choices) < 1 {
-		return "", errors.New("empty response from model")
+		return "", ErrEmptyResponse
 	}
 	c1 := choices[0]
 	return c1.Content, nil
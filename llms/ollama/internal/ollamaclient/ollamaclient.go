@@ -74,6 +74,11 @@ func NewClient(ourl *url.URL, ohttp *http.Client) (*Client, error) {
 	return &client, nil
 }
 
+// URL returns the base URL of the server.
+func (c *Client) URL() string {
+	return c.base.String()
+}
+
 func (c *Client) do(ctx context.Context, method, path string, reqData, respData any) error {
 	var reqBody io.Reader
 	var data []byte
@@ -211,3 +216,12 @@ func (c *Client) GenerateChat(ctx context.Context, req *ChatRequest, fn ChatResp
 		return fn(resp)
 	})
 }
+
+// Show returns the details of a model, including its capabilities.
+func (c *Client) Show(ctx context.Context, req *ShowRequest) (*ShowResponse, error) {
+	resp := &ShowResponse{}
+	if err := c.do(ctx, http.MethodPost, "/api/show", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -1,6 +1,7 @@
 package ollamaclient
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"time"
@@ -47,12 +48,69 @@ type Message struct {
 }
 
 type ChatRequest struct {
-	Model     string     `json:"model"`
-	Messages  []*Message `json:"messages"`
-	Stream    bool       `json:"stream,omitempty"`
-	Format    string     `json:"format"`
-	KeepAlive string     `json:"keep_alive,omitempty"`
-	Options   Options    `json:"options"`
+	Model       string     `json:"model"`
+	Messages    []*Message `json:"messages"`
+	Stream      bool       `json:"stream,omitempty"`
+	Format      string     `json:"format"`
+	KeepAlive   string     `json:"keep_alive,omitempty"`
+	Logprobs    bool       `json:"logprobs,omitempty"`
+	TopLogprobs int        `json:"top_logprobs,omitempty"`
+	Options     Options    `json:"options"`
+}
+
+type TokenLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []byte  `json:"bytes,omitempty"`
+}
+
+// UnmarshalJSON decodes bytes given as an array of numbers.
+func (t *TokenLogprob) UnmarshalJSON(data []byte) error {
+	type alias TokenLogprob
+	var raw struct {
+		alias
+		Bytes []int `json:"bytes,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*t = TokenLogprob(raw.alias)
+	t.Bytes = intsToBytes(raw.Bytes)
+	return nil
+}
+
+type Logprob struct {
+	TokenLogprob
+	TopLogprobs []TokenLogprob `json:"top_logprobs,omitempty"`
+}
+
+// UnmarshalJSON decodes bytes given as an array of numbers.
+func (l *Logprob) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Token       string         `json:"token"`
+		Logprob     float64        `json:"logprob"`
+		Bytes       []int          `json:"bytes,omitempty"`
+		TopLogprobs []TokenLogprob `json:"top_logprobs,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	l.Token = raw.Token
+	l.Logprob = raw.Logprob
+	l.Bytes = intsToBytes(raw.Bytes)
+	l.TopLogprobs = raw.TopLogprobs
+	return nil
+}
+
+func intsToBytes(ints []int) []byte {
+	if ints == nil {
+		return nil
+	}
+	b := make([]byte, len(ints))
+	for i, v := range ints {
+		b[i] = byte(v)
+	}
+	return b
 }
 
 type Metrics struct {
@@ -123,6 +181,7 @@ type ChatResponse struct {
 	CreatedAt time.Time `json:"created_at"`
 	Message   *Message  `json:"message,omitempty"`
 	Done      bool      `json:"done"`
+	Logprobs  []Logprob `json:"logprobs,omitempty"`
 	Metrics
 }
 
@@ -137,31 +196,52 @@ type Runner struct {
 	RopeFrequencyScale float32 `json:"rope_frequency_scale,omitempty"`
 	LogitsAll          bool    `json:"logits_all,omitempty"`
 	VocabOnly          bool    `json:"vocab_only,omitempty"`
-	UseMMap            bool    `json:"use_mmap,omitempty"`
+	UseMMap            *bool   `json:"use_mmap,omitempty"`
 	UseMLock           bool    `json:"use_mlock,omitempty"`
 	EmbeddingOnly      bool    `json:"embedding_only,omitempty"`
 	UseNUMA            bool    `json:"numa,omitempty"`
-	F16KV              bool    `json:"f16_kv,omitempty"`
+	F16KV              *bool   `json:"f16_kv,omitempty"`
 	LowVRAM            bool    `json:"low_vram,omitempty"`
 }
 
 type Options struct {
 	Stop []string `json:"stop,omitempty"`
 	Runner
-	RepeatLastN      int     `json:"repeat_last_n,omitempty"`
-	Seed             int     `json:"seed,omitempty"`
-	TopK             int     `json:"top_k,omitempty"`
-	NumKeep          int     `json:"num_keep,omitempty"`
-	Mirostat         int     `json:"mirostat,omitempty"`
-	NumPredict       int     `json:"num_predict,omitempty"`
-	Temperature      float32 `json:"temperature"`
-	TypicalP         float32 `json:"typical_p,omitempty"`
-	RepeatPenalty    float32 `json:"repeat_penalty,omitempty"`
-	PresencePenalty  float32 `json:"presence_penalty,omitempty"`
-	FrequencyPenalty float32 `json:"frequency_penalty,omitempty"`
-	TFSZ             float32 `json:"tfs_z,omitempty"`
-	MirostatTau      float32 `json:"mirostat_tau,omitempty"`
-	MirostatEta      float32 `json:"mirostat_eta,omitempty"`
-	TopP             float32 `json:"top_p,omitempty"`
-	PenalizeNewline  bool    `json:"penalize_newline,omitempty"`
+	RepeatLastN      int      `json:"repeat_last_n,omitempty"`
+	Seed             int      `json:"seed,omitempty"`
+	TopK             int      `json:"top_k,omitempty"`
+	NumKeep          int      `json:"num_keep,omitempty"`
+	Mirostat         int      `json:"mirostat,omitempty"`
+	NumPredict       int      `json:"num_predict,omitempty"`
+	Temperature      *float32 `json:"temperature,omitempty"`
+	TypicalP         float32  `json:"typical_p,omitempty"`
+	RepeatPenalty    float32  `json:"repeat_penalty,omitempty"`
+	PresencePenalty  float32  `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float32  `json:"frequency_penalty,omitempty"`
+	TFSZ             float32  `json:"tfs_z,omitempty"`
+	MirostatTau      float32  `json:"mirostat_tau,omitempty"`
+	MirostatEta      float32  `json:"mirostat_eta,omitempty"`
+	TopP             float32  `json:"top_p,omitempty"`
+	PenalizeNewline  *bool    `json:"penalize_newline,omitempty"`
+}
+
+type ShowRequest struct {
+	Model string `json:"model"`
+}
+
+type ModelDetails struct {
+	Format            string   `json:"format,omitempty"`
+	Family            string   `json:"family,omitempty"`
+	Families          []string `json:"families,omitempty"`
+	ParameterSize     string   `json:"parameter_size,omitempty"`
+	QuantizationLevel string   `json:"quantization_level,omitempty"`
+}
+
+type ShowResponse struct {
+	Modelfile    string         `json:"modelfile,omitempty"`
+	Parameters   string         `json:"parameters,omitempty"`
+	Template     string         `json:"template,omitempty"`
+	Details      ModelDetails   `json:"details,omitempty"`
+	ModelInfo    map[string]any `json:"model_info,omitempty"`
+	Capabilities []string       `json:"capabilities,omitempty"`
 }
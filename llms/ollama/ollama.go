@@ -3,6 +3,10 @@ package ollama
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 
 	"github.com/mateors/llmg/callbacks"
 	"github.com/mateors/llmg/llms"
@@ -12,6 +16,7 @@ import (
 var (
 	ErrEmptyResponse       = errors.New("no response")
 	ErrIncompleteEmbedding = errors.New("not all input got embedded")
+	ErrInvalidCallOption   = errors.New("invalid call option")
 )
 
 // LLM is a ollama LLM implementation.
@@ -21,7 +26,10 @@ type LLM struct {
 	options          options
 }
 
-var _ llms.Model = (*LLM)(nil)
+var (
+	_ llms.Model              = (*LLM)(nil)
+	_ llms.CapabilityReporter = (*LLM)(nil)
+)
 
 // New creates a new ollama LLM implementation.
 func New(opts ...Option) (*LLM, error) {
@@ -42,10 +50,6 @@ func New(opts ...Option) (*LLM, error) {
 // nolint: goerr113
 func (o *LLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) { // nolint: lll, cyclop, funlen
 
-	if o.CallbacksHandler != nil {
-		o.CallbacksHandler.HandleLLMGenerateContentStart(ctx, messages)
-	}
-
 	opts := llms.CallOptions{}
 	for _, opt := range options {
 		opt(&opts)
@@ -57,12 +61,29 @@ func (o *LLM) GenerateContent(ctx context.Context, messages []llms.MessageConten
 		model = opts.Model
 	}
 
+	ctx, _ = callbacks.StartRun(ctx, callbacks.RunTypeLLM, model)
+	handler := callbacks.Combine(ctx, o.CallbacksHandler)
+	if handler != nil {
+		handler.HandleLLMGenerateContentStart(ctx, messages)
+	}
+
+	// Chat requests do not carry tools, fail instead of silently dropping them.
+	if len(opts.Tools) > 0 {
+		err := fmt.Errorf("%w: ollama client does not send %s, wrap the model with toolshim.New",
+			llms.ErrCapabilityNotSupported, llms.CapabilityTools)
+		if handler != nil {
+			handler.HandleLLMError(ctx, err)
+		}
+		return nil, err
+	}
+
 	// Our input is a sequence of MessageContent, each of which potentially has
 	// a sequence of Part that could be text, images etc.
 	// We have to convert it to a format Ollama undestands: ChatRequest, which
 	// has a sequence of Message, each of which has a role and content - single
 	// text + potential images.
 	chatMsgs := make([]*ollamaclient.Message, 0, len(messages))
+	hasImages := false
 
 	for _, mc := range messages {
 
@@ -91,56 +112,83 @@ func (o *LLM) GenerateContent(ctx context.Context, messages []llms.MessageConten
 
 		msg.Content = text
 		msg.Images = images
+		hasImages = hasImages || len(images) > 0
 		chatMsgs = append(chatMsgs, msg)
 	}
 
+	// Fail early instead of letting the server silently drop the images. If
+	// the capabilities cannot be discovered, the chat request reports why.
+	if hasImages {
+		if caps, err := o.capabilitiesFor(ctx, model); err == nil && !caps.Vision {
+			err = fmt.Errorf("%w: model %q does not support %s",
+				llms.ErrCapabilityNotSupported, model, llms.CapabilityVision)
+			if handler != nil {
+				handler.HandleLLMError(ctx, err)
+			}
+			return nil, err
+		}
+	}
+
 	format := o.options.format
 	if opts.JSONMode {
 		format = "json"
 	}
 
-	// Get our ollamaOptions from llms.CallOptions
-	ollamaOptions := makeOllamaOptionsFromOptions(o.options.ollamaOptions, opts)
-	req := &ollamaclient.ChatRequest{
-		Model:    model,
-		Format:   format,
-		Messages: chatMsgs,
-		Options:  ollamaOptions,
-		Stream:   opts.StreamingFunc != nil,
+	callOpts, err := o.callOptions(opts, options)
+	if err != nil {
+		if handler != nil {
+			handler.HandleLLMError(ctx, err)
+		}
+		return nil, err
 	}
 
-	keepAlive := o.options.keepAlive
-	if keepAlive != "" {
-		req.KeepAlive = keepAlive
+	req := &ollamaclient.ChatRequest{
+		Model:     model,
+		Format:    format,
+		Messages:  chatMsgs,
+		Options:   callOpts.ollamaOptions,
+		Stream:    opts.StreamingFunc != nil,
+		KeepAlive: callOpts.keepAlive,
+	}
+	if opts.LogProbs || opts.TopLogProbs > 0 {
+		req.Logprobs = true
+		req.TopLogprobs = opts.TopLogProbs
 	}
 
 	var fn ollamaclient.ChatResponseFunc
 	streamedResponse := ""
 	var resp ollamaclient.ChatResponse
+	var logprobs []ollamaclient.Logprob
 
 	fn = func(response ollamaclient.ChatResponse) error {
 		if opts.StreamingFunc != nil && response.Message != nil {
-			if err := opts.StreamingFunc(ctx, []byte(response.Message.Content)); err != nil {
+			chunk := []byte(response.Message.Content)
+			if handler != nil {
+				handler.HandleStreamingFunc(ctx, chunk)
+			}
+			if err := opts.StreamingFunc(ctx, chunk); err != nil {
 				return err
 			}
 		}
 		if response.Message != nil {
 			streamedResponse += response.Message.Content
 		}
+		logprobs = append(logprobs, response.Logprobs...)
 		if !req.Stream || response.Done {
 			resp = response
 			resp.Message = &ollamaclient.Message{
 				Role:    "assistant",
 				Content: streamedResponse,
 			}
+			resp.Logprobs = logprobs
 		}
 		return nil
 	}
 
-	err := o.client.GenerateChat(ctx, req, fn)
+	err = o.client.GenerateChat(ctx, req, fn)
 	if err != nil {
-		if o.CallbacksHandler != nil {
-			o.CallbacksHandler.HandleLLMError(ctx, err)
+		if handler != nil {
+			handler.HandleLLMError(ctx, err)
 		}
 		return nil, err
 	}
@@ -153,13 +201,14 @@ func (o *LLM) GenerateContent(ctx context.Context, messages []llms.MessageConten
 				"PromptTokens":     resp.PromptEvalCount,
 				"TotalTokens":      resp.EvalCount + resp.PromptEvalCount,
 			},
+			LogProbs: makeLogProbs(resp.Logprobs),
 		},
 	}
 
 	response := &llms.ContentResponse{Choices: choices}
 
-	if o.CallbacksHandler != nil {
-		o.CallbacksHandler.HandleLLMGenerateContentEnd(ctx, response)
+	if handler != nil {
+		handler.HandleLLMGenerateContentEnd(ctx, response)
 	}
 
 	return response, nil
@@ -171,11 +220,10 @@ func (o *LLM) CreateEmbedding(ctx context.Context, inputTexts []string) ([][]flo
 
 	for _, input := range inputTexts {
 		req := &ollamaclient.EmbeddingRequest{
-			Prompt: input,
-			Model:  o.options.model,
-		}
-		if o.options.keepAlive != "" {
-			req.KeepAlive = o.options.keepAlive
+			Prompt:    input,
+			Model:     o.options.model,
+			Options:   o.options.ollamaOptions,
+			KeepAlive: o.options.keepAlive,
 		}
 
 		embedding, err := o.client.CreateEmbedding(ctx, req)
@@ -197,6 +245,112 @@ func (o *LLM) CreateEmbedding(ctx context.Context, inputTexts []string) ([][]flo
 	return embeddings, nil
 }
 
+// Capabilities implements llms.CapabilityReporter. The capabilities are
+// discovered with /api/show and cached in llms.DefaultCapabilityRegistry for
+// the server, where they can also be overridden. Unless overridden, the
+// context length is the num_ctx the requests of this LLM are sent with if it
+// is set, otherwise the one configured in the model's parameters, or the one
+// the model was trained with as a last resort.
+func (o *LLM) Capabilities(ctx context.Context) (llms.Capabilities, error) {
+	return o.capabilitiesFor(ctx, o.options.model)
+}
+
+func (o *LLM) capabilitiesFor(ctx context.Context, model string) (llms.Capabilities, error) {
+	registry := llms.DefaultCapabilityRegistry
+	source := "ollama " + o.client.URL()
+	caps, err := registry.Resolve(ctx, source, model, func(ctx context.Context) (llms.Capabilities, error) {
+		return o.discoverCapabilities(ctx, model)
+	})
+	if err != nil {
+		return caps, err
+	}
+	// The registry is shared by all instances, num_ctx is not.
+	if _, overridden := registry.Overridden(model); !overridden && o.options.ollamaOptions.NumCtx != 0 {
+		caps.ContextLength = o.options.ollamaOptions.NumCtx
+	}
+	return caps, nil
+}
+
+func (o *LLM) discoverCapabilities(ctx context.Context, model string) (llms.Capabilities, error) {
+	resp, err := o.client.Show(ctx, &ollamaclient.ShowRequest{Model: model})
+	if err != nil {
+		return llms.Capabilities{}, fmt.Errorf("show model %q: %w", model, err)
+	}
+
+	caps := llms.Capabilities{JSONSchema: true}
+	if len(resp.Capabilities) > 0 {
+		for _, c := range resp.Capabilities {
+			switch c {
+			case "tools":
+				caps.Tools = true
+			case "vision":
+				caps.Vision = true
+			case "thinking":
+				caps.Thinking = true
+			case "embedding":
+				caps.Embeddings = true
+				caps.JSONSchema = false
+			}
+		}
+	} else {
+		// Older servers do not report capabilities, guess them from the model.
+		caps.Tools = strings.Contains(resp.Template, ".Tools")
+		for _, family := range append(resp.Details.Families, resp.Details.Family) {
+			switch family {
+			case "clip", "mllama":
+				caps.Vision = true
+			case "bert", "nomic-bert":
+				caps.Embeddings = true
+			}
+		}
+	}
+
+	caps.ContextLength = parameterNumCtx(resp.Parameters)
+	if caps.ContextLength == 0 {
+		caps.ContextLength = trainedContextLength(resp.ModelInfo)
+	}
+	return caps, nil
+}
+
+// parameterNumCtx returns num_ctx from the parameters of a modelfile.
+func parameterNumCtx(parameters string) int {
+	for _, line := range strings.Split(parameters, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "num_ctx" {
+			n, err := strconv.Atoi(fields[1])
+			if err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// trainedContextLength returns <architecture>.context_length from the model info.
+func trainedContextLength(modelInfo map[string]any) int {
+	arch, _ := modelInfo["general.architecture"].(string)
+	if n, ok := modelInfo[arch+".context_length"].(float64); ok {
+		return int(n)
+	}
+	return 0
+}
+
+func makeLogProbs(logprobs []ollamaclient.Logprob) []llms.TokenLogProb {
+	if len(logprobs) == 0 {
+		return nil
+	}
+
+	result := make([]llms.TokenLogProb, 0, len(logprobs))
+	for _, lp := range logprobs {
+		tlp := llms.TokenLogProb{Token: lp.Token, LogProb: lp.Logprob, Bytes: lp.Bytes}
+		for _, top := range lp.TopLogprobs {
+			tlp.TopLogProbs = append(tlp.TopLogProbs, llms.TopLogProb{Token: top.Token, LogProb: top.Logprob, Bytes: top.Bytes})
+		}
+		result = append(result, tlp)
+	}
+	return result
+}
+
 func typeToRole(typ llms.ChatMessageType) string {
 	switch typ {
 	case llms.ChatMessageTypeSystem:
@@ -215,18 +369,82 @@ func typeToRole(typ llms.ChatMessageType) string {
 	return ""
 }
 
+// callOptions returns the options of a single call: the options the LLM was
+// created with, overridden by the runtime options given with WithCallOptions
+// and then by the generic call options that were explicitly set.
+func (o *LLM) callOptions(opts llms.CallOptions, callOpts []llms.CallOption) (options, error) {
+	result := o.options
+	if value, ok := opts.Metadata[metadataOptions]; ok {
+		ollamaOpts, ok := value.(runtimeOptions)
+		if !ok {
+			return result, fmt.Errorf("%w: %s: %v", ErrInvalidCallOption, metadataOptions, value)
+		}
+		for i, opt := range ollamaOpts {
+			if !isRuntimeOption(opt) {
+				return result, fmt.Errorf("%w: %s: option %d is not a runtime option",
+					ErrInvalidCallOption, metadataOptions, i)
+			}
+			opt(&result)
+		}
+	}
+
+	result.ollamaOptions = makeOllamaOptionsFromOptions(result.ollamaOptions, explicitCallOptions(callOpts))
+	return result, nil
+}
+
+// explicitCallOptions applies the call options to sentinel values, so that
+// options explicitly set to their zero value can be told apart from options
+// that were not set at all.
+func explicitCallOptions(callOpts []llms.CallOption) llms.CallOptions {
+	opts := llms.CallOptions{
+		MaxTokens:         math.MinInt,
+		Temperature:       math.Inf(-1),
+		TopK:              math.MinInt,
+		TopP:              math.Inf(-1),
+		Seed:              math.MinInt,
+		RepetitionPenalty: math.Inf(-1),
+		FrequencyPenalty:  math.Inf(-1),
+		PresencePenalty:   math.Inf(-1),
+	}
+	for _, opt := range callOpts {
+		opt(&opts)
+	}
+	return opts
+}
+
+// makeOllamaOptionsFromOptions overrides the ollama options with the call
+// options that were set, see explicitCallOptions.
 func makeOllamaOptionsFromOptions(ollamaOptions ollamaclient.Options, opts llms.CallOptions) ollamaclient.Options {
+	isSet := func(v float64) bool { return !math.IsInf(v, -1) }
 
-	// Load back CallOptions as ollamaOptions
-	ollamaOptions.NumPredict = opts.MaxTokens
-	ollamaOptions.Temperature = float32(opts.Temperature)
-	ollamaOptions.Stop = opts.StopWords
-	ollamaOptions.TopK = opts.TopK
-	ollamaOptions.TopP = float32(opts.TopP)
-	ollamaOptions.Seed = opts.Seed
-	ollamaOptions.RepeatPenalty = float32(opts.RepetitionPenalty)
-	ollamaOptions.FrequencyPenalty = float32(opts.FrequencyPenalty)
-	ollamaOptions.PresencePenalty = float32(opts.PresencePenalty)
+	if opts.MaxTokens != math.MinInt {
+		ollamaOptions.NumPredict = opts.MaxTokens
+	}
+	if isSet(opts.Temperature) {
+		temperature := float32(opts.Temperature)
+		ollamaOptions.Temperature = &temperature
+	}
+	if opts.StopWords != nil {
+		ollamaOptions.Stop = opts.StopWords
+	}
+	if opts.TopK != math.MinInt {
+		ollamaOptions.TopK = opts.TopK
+	}
+	if isSet(opts.TopP) {
+		ollamaOptions.TopP = float32(opts.TopP)
+	}
+	if opts.Seed != math.MinInt {
+		ollamaOptions.Seed = opts.Seed
+	}
+	if isSet(opts.RepetitionPenalty) {
+		ollamaOptions.RepeatPenalty = float32(opts.RepetitionPenalty)
+	}
+	if isSet(opts.FrequencyPenalty) {
+		ollamaOptions.FrequencyPenalty = float32(opts.FrequencyPenalty)
+	}
+	if isSet(opts.PresencePenalty) {
+		ollamaOptions.PresencePenalty = float32(opts.PresencePenalty)
+	}
 
 	return ollamaOptions
 }
@@ -1,10 +1,14 @@
 package ollama
 
 import (
+	"encoding/json"
 	"log"
+	"maps"
 	"net/http"
 	"net/url"
+	"reflect"
 
+	"github.com/mateors/llmg/llms"
 	"github.com/mateors/llmg/llms/ollama/internal/ollamaclient"
 )
 
@@ -69,3 +73,307 @@ func WithRunnerEmbeddingOnly(val bool) Option {
 		opts.ollamaOptions.EmbeddingOnly = val
 	}
 }
+
+// WithKeepAlive Set how long the model stays loaded after a request, e.g.
+// "5m", "1h" or "-1" to keep it loaded indefinitely.
+func WithKeepAlive(keepAlive string) Option {
+	return func(opts *options) {
+		opts.keepAlive = keepAlive
+	}
+}
+
+// WithRunnerNumCtx Sets the size of the context window used to generate the next token.
+func WithRunnerNumCtx(num int) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.NumCtx = num
+	}
+}
+
+// WithRunnerNumBatch Sets the batch size for prompt processing.
+func WithRunnerNumBatch(num int) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.NumBatch = num
+	}
+}
+
+// WithRunnerNumGQA The number of GQA groups in the transformer layer. Required for some models.
+func WithRunnerNumGQA(num int) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.NumGQA = num
+	}
+}
+
+// WithRunnerNumGPU The number of layers to send to the GPU(s).
+// On macOS it defaults to 1 to enable metal support, 0 to disable.
+func WithRunnerNumGPU(num int) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.NumGPU = num
+	}
+}
+
+// WithRunnerMainGPU When using multiple GPUs this option controls which GPU is used
+// for small tensors for which the overhead of splitting the computation across all GPUs
+// is not worthwhile. The GPU in question will use slightly more VRAM to store a scratch
+// buffer for temporary results.
+func WithRunnerMainGPU(num int) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.MainGPU = num
+	}
+}
+
+// WithRunnerNumThread Sets the number of threads to use during computation.
+// By default, Ollama will detect this for optimal performance.
+// It is recommended to set this value to the number of physical CPU cores
+// your system has (as opposed to the logical number of cores).
+func WithRunnerNumThread(num int) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.NumThread = num
+	}
+}
+
+// WithRunnerRopeFrequencyBase RoPE base frequency.
+func WithRunnerRopeFrequencyBase(val float32) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.RopeFrequencyBase = val
+	}
+}
+
+// WithRunnerRopeFrequencyScale RoPE frequency scaling factor.
+func WithRunnerRopeFrequencyScale(val float32) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.RopeFrequencyScale = val
+	}
+}
+
+// WithRunnerLogitsAll Return logits for all tokens, not just the last token.
+func WithRunnerLogitsAll(val bool) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.LogitsAll = val
+	}
+}
+
+// WithRunnerVocabOnly Only load the vocabulary, no weights.
+func WithRunnerVocabOnly(val bool) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.VocabOnly = val
+	}
+}
+
+// WithRunnerUseMMap Set to false to not memory-map the model.
+// By default, models are mapped into memory, which allows the system to load only the necessary parts
+// of the model as needed.
+func WithRunnerUseMMap(val bool) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.UseMMap = &val
+	}
+}
+
+// WithRunnerUseMLock Force system to keep model in RAM.
+func WithRunnerUseMLock(val bool) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.UseMLock = val
+	}
+}
+
+// WithRunnerUseNUMA Use NUMA optimization on certain systems.
+func WithRunnerUseNUMA(numa bool) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.UseNUMA = numa
+	}
+}
+
+// WithRunnerF16KV If set to false, use 32-bit floats instead of 16-bit floats for the KV cache.
+func WithRunnerF16KV(val bool) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.F16KV = &val
+	}
+}
+
+// WithRunnerLowVRAM Do not allocate a VRAM scratch buffer for holding temporary results.
+// Reduces VRAM usage at the cost of performance, particularly prompt processing speed.
+func WithRunnerLowVRAM(val bool) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.LowVRAM = val
+	}
+}
+
+// WithPredictNumPredict Sets the maximum number of tokens to predict
+// (-1 for infinite generation, -2 to fill the context).
+func WithPredictNumPredict(num int) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.NumPredict = num
+	}
+}
+
+// WithPredictNumKeep Sets the number of tokens from the initial prompt to retain
+// when the context is shifted.
+func WithPredictNumKeep(num int) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.NumKeep = num
+	}
+}
+
+// WithPredictStop Sets the stop sequences to use.
+func WithPredictStop(stop []string) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.Stop = stop
+	}
+}
+
+// WithPredictSeed Sets the random number seed to use for generation. Setting this
+// to a specific number will make the model generate the same text for the same prompt.
+func WithPredictSeed(seed int) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.Seed = seed
+	}
+}
+
+// WithPredictTemperature The temperature of the model. Increasing the temperature
+// will make the model answer more creatively.
+func WithPredictTemperature(temperature float32) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.Temperature = &temperature
+	}
+}
+
+// WithPredictTopK Reduces the probability of generating nonsense. A higher value
+// (e.g. 100) will give more diverse answers, while a lower value (e.g. 10) will be
+// more conservative.
+func WithPredictTopK(topK int) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.TopK = topK
+	}
+}
+
+// WithPredictTopP Works together with top-k. A higher value (e.g., 0.95) will lead
+// to more diverse text, while a lower value (e.g., 0.5) will generate more focused
+// and conservative text.
+func WithPredictTopP(topP float32) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.TopP = topP
+	}
+}
+
+// WithPredictTFSZ Tail free sampling is used to reduce the impact of less probable
+// tokens from the output. A higher value (e.g., 2.0) will reduce the impact more,
+// while a value of 1.0 disables this setting.
+func WithPredictTFSZ(val float32) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.TFSZ = val
+	}
+}
+
+// WithPredictTypicalP Sets the typical_p value, 1.0 disables locally typical sampling.
+func WithPredictTypicalP(val float32) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.TypicalP = val
+	}
+}
+
+// WithPredictRepeatLastN Sets how far back the model looks back to prevent repetition
+// (0 = disabled, -1 = num_ctx).
+func WithPredictRepeatLastN(val int) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.RepeatLastN = val
+	}
+}
+
+// WithPredictRepeatPenalty Sets how strongly to penalize repetitions. A higher value
+// (e.g., 1.5) will penalize repetitions more strongly, while a lower value (e.g., 0.9)
+// will be more lenient.
+func WithPredictRepeatPenalty(val float32) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.RepeatPenalty = val
+	}
+}
+
+// WithPredictPresencePenalty Penalizes tokens that already appeared in the text.
+func WithPredictPresencePenalty(val float32) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.PresencePenalty = val
+	}
+}
+
+// WithPredictFrequencyPenalty Penalizes tokens by how often they already appeared in the text.
+func WithPredictFrequencyPenalty(val float32) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.FrequencyPenalty = val
+	}
+}
+
+// WithPredictMirostat Enable Mirostat sampling for controlling perplexity
+// (default: 0, 0 = disabled, 1 = Mirostat, 2 = Mirostat 2.0).
+func WithPredictMirostat(val int) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.Mirostat = val
+	}
+}
+
+// WithPredictMirostatTau Controls the balance between coherence and diversity of the output.
+// A lower value will result in more focused and coherent text (Default: 5.0).
+func WithPredictMirostatTau(val float32) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.MirostatTau = val
+	}
+}
+
+// WithPredictMirostatEta Influences how quickly the algorithm responds to feedback from
+// the generated text. A lower learning rate will result in slower adjustments, while a
+// higher learning rate will make the algorithm more responsive (Default: 0.1).
+func WithPredictMirostatEta(val float32) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.MirostatEta = val
+	}
+}
+
+// WithPredictPenalizeNewline Penalize newline tokens when applying the repeat penalty.
+func WithPredictPenalizeNewline(val bool) Option {
+	return func(opts *options) {
+		opts.ollamaOptions.PenalizeNewline = &val
+	}
+}
+
+// metadataOptions is the key of the per-call options stored in llms.CallOptions.Metadata.
+const metadataOptions = "ollama.options"
+
+// runtimeOptions are the options given with WithCallOptions. They marshal to
+// the settings they apply, so that they can be part of llms.BatchKey.
+type runtimeOptions []Option
+
+// isRuntimeOption reports whether opt only sets runtime options.
+func isRuntimeOption(opt Option) bool {
+	var o options
+	opt(&o)
+	o.ollamaOptions, o.keepAlive = ollamaclient.Options{}, ""
+	return reflect.ValueOf(o).IsZero()
+}
+
+func (r runtimeOptions) MarshalJSON() ([]byte, error) {
+	var o options
+	for _, opt := range r {
+		opt(&o)
+	}
+	return json.Marshal(struct {
+		Options   ollamaclient.Options `json:"options"`
+		KeepAlive string               `json:"keep_alive,omitempty"`
+	}{o.ollamaOptions, o.keepAlive})
+}
+
+// WithCallOptions applies the runtime options (WithRunner*, WithPredict* and
+// WithKeepAlive) to a single call, overriding the ones the LLM was created
+// with. Other options make the call fail with ErrInvalidCallOption. Generic
+// call options such as llms.WithTemperature take precedence over these.
+func WithCallOptions(opts ...Option) llms.CallOption {
+	return func(o *llms.CallOptions) {
+		var callOpts runtimeOptions
+		if previous, ok := o.Metadata[metadataOptions].(runtimeOptions); ok {
+			callOpts = append(callOpts, previous...)
+		}
+		callOpts = append(callOpts, opts...)
+
+		metadata := make(map[string]any, len(o.Metadata)+1)
+		maps.Copy(metadata, o.Metadata)
+		metadata[metadataOptions] = callOpts
+		o.Metadata = metadata
+	}
+}
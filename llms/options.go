@@ -1,6 +1,9 @@
 package llms
 
-import "context"
+import (
+	"context"
+	"reflect"
+)
 
 // CallOption is a function that configures a CallOptions.
 type CallOption func(*CallOptions)
@@ -65,6 +68,12 @@ type CallOptions struct {
 	// The meaning of this field is specific to the backend in use.
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
 
+	// LogProbs is a flag to request the log probability of each generated token.
+	LogProbs bool `json:"logprobs,omitempty"`
+	// TopLogProbs is the number of most likely alternative tokens to return,
+	// with their log probabilities, at each position. Implies LogProbs.
+	TopLogProbs int `json:"top_logprobs,omitempty"`
+
 	// ResponseMIMEType MIME type of the generated candidate text.
 	// Supported MIME types are: text/plain: (default) Text output.
 	// application/json: JSON response in the response candidates.
@@ -116,10 +125,18 @@ func WithTemperature(temperature float64) CallOption {
 	}
 }
 
-// WithOptions specifies options.
+// WithOptions specifies options. Only the fields that are not zero are set,
+// the others keep the value given by the previous options, so that providers
+// can still tell which options were set. Use the other options, e.g.
+// WithTemperature, to set a field to its zero value.
 func WithOptions(options CallOptions) CallOption {
 	return func(o *CallOptions) {
-		(*o) = options
+		src, dst := reflect.ValueOf(options), reflect.ValueOf(o).Elem()
+		for i := range src.NumField() {
+			if !src.Field(i).IsZero() {
+				dst.Field(i).Set(src.Field(i))
+			}
+		}
 	}
 }
 
@@ -201,3 +218,23 @@ func WithRepetitionPenalty(repetitionPenalty float64) CallOption {
 		o.RepetitionPenalty = repetitionPenalty
 	}
 }
+
+// WithLogProbs will add an option to request the log probability of each
+// generated token, returned in ContentChoice.LogProbs.
+func WithLogProbs(logProbs bool) CallOption {
+	return func(o *CallOptions) {
+		o.LogProbs = logProbs
+	}
+}
+
+// WithTopLogProbs will add an option to request the n most likely alternative
+// tokens at each position along with their log probabilities. It implies
+// WithLogProbs(true).
+func WithTopLogProbs(n int) CallOption {
+	return func(o *CallOptions) {
+		o.TopLogProbs = n
+		if n > 0 {
+			o.LogProbs = true
+		}
+	}
+}
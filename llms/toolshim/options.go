@@ -0,0 +1,37 @@
+package toolshim
+
+import "github.com/mateors/llmg/llms"
+
+type options struct {
+	instructionTemplate string
+	toolResultRole      llms.ChatMessageType
+}
+
+// Option is a function that configures the tool-calling shim.
+type Option func(*options)
+
+func defaultOptions() options {
+	return options{
+		instructionTemplate: _defaultInstructionTemplate,
+		toolResultRole:      llms.ChatMessageTypeHuman,
+	}
+}
+
+// WithInstructionTemplate sets the go-template used to build the tool-usage
+// system instruction. The template receives the "tools" variable holding the
+// JSON description of every tool and the "tool_choice" variable holding an
+// extra sentence derived from llms.WithToolChoice, which may be empty.
+func WithInstructionTemplate(template string) Option {
+	return func(o *options) {
+		o.instructionTemplate = template
+	}
+}
+
+// WithToolResultRole sets the role used for tool results once they have been
+// rewritten into text. Defaults to llms.ChatMessageTypeHuman, since models
+// without tool support usually drop or mangle messages with a tool role.
+func WithToolResultRole(role llms.ChatMessageType) Option {
+	return func(o *options) {
+		o.toolResultRole = role
+	}
+}
@@ -0,0 +1,388 @@
+package toolshim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/prompts"
+)
+
+// ErrUnsupportedToolChoice is returned when the tool choice cannot be
+// expressed as an instruction to the model.
+var ErrUnsupportedToolChoice = errors.New("unsupported tool choice")
+
+const _defaultInstructionTemplate = `You have access to the following tools:
+
+{{.tools}}
+
+To use one or more tools, reply with only a JSON object of the following form and nothing else:
+{"tool_calls": [{"name": "<tool name>", "arguments": {<arguments matching the tool parameters>}}]}
+
+If no tool is needed, reply to the user directly in plain text.{{.tool_choice}}`
+
+// LLM wraps a model that has no native tool calling and emulates it through
+// prompting. When tools are passed with llms.WithTools, a system instruction
+// describing them is injected, the model's JSON reply is parsed into
+// ContentChoice.ToolCalls and tool calls and tool results already present in
+// the conversation are rewritten as text, so the wrapped model only ever sees
+// plain text messages.
+//
+// Streamed chunks are forwarded unchanged, so a streaming function receives
+// the raw JSON when the model decides to call a tool.
+type LLM struct {
+	model   llms.Model
+	options options
+}
+
+var (
+	_ llms.Model              = (*LLM)(nil)
+	_ llms.CapabilityReporter = (*LLM)(nil)
+)
+
+// New wraps model with the tool-calling shim.
+func New(model llms.Model, opts ...Option) *LLM {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &LLM{model: model, options: o}
+}
+
+// GenerateContent implements the Model interface.
+func (l *LLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) { // nolint: lll
+	opts := llms.CallOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	tools := opts.Tools
+	for _, fn := range opts.Functions {
+		tools = append(tools, llms.Tool{Type: "function", Function: &fn})
+	}
+	if choice, ok := opts.ToolChoice.(string); ok && choice == "none" {
+		tools = nil
+	}
+
+	msgs, err := l.rewriteMessages(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tools) > 0 {
+		instruction, err := l.instruction(tools, opts.ToolChoice)
+		if err != nil {
+			return nil, err
+		}
+		msgs = injectSystemInstruction(msgs, instruction)
+	}
+
+	// The wrapped model must never see the tools, it would either reject or
+	// silently ignore them.
+	options = append(options, func(o *llms.CallOptions) {
+		o.Tools = nil
+		o.ToolChoice = nil
+		o.Functions = nil
+		o.FunctionCallBehavior = ""
+	})
+
+	resp, err := l.model.GenerateContent(ctx, msgs, options...)
+	if err != nil {
+		return nil, err
+	}
+	if len(tools) == 0 {
+		return resp, nil
+	}
+
+	for _, choice := range resp.Choices {
+		toolCalls, ok := parseToolCalls(choice.Content)
+		if !ok {
+			continue
+		}
+		choice.Content = ""
+		choice.ToolCalls = toolCalls
+		choice.FuncCall = toolCalls[0].FunctionCall
+		choice.StopReason = "tool_calls"
+	}
+
+	return resp, nil
+}
+
+// Capabilities implements llms.CapabilityReporter. It reports the
+// capabilities of the wrapped model with tool calling added.
+func (l *LLM) Capabilities(ctx context.Context) (llms.Capabilities, error) {
+	caps, err := llms.GetCapabilities(ctx, l.model)
+	if err != nil && !errors.Is(err, llms.ErrCapabilitiesUnknown) {
+		return llms.Capabilities{}, err
+	}
+	caps.Tools = true
+	return caps, nil
+}
+
+// instruction renders the tool-usage system instruction.
+func (l *LLM) instruction(tools []llms.Tool, toolChoice any) (string, error) {
+	descriptions := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		b, err := json.Marshal(tool.Function)
+		if err != nil {
+			return "", err
+		}
+		descriptions = append(descriptions, string(b))
+	}
+
+	choice, err := toolChoiceSentence(toolChoice)
+	if err != nil {
+		return "", err
+	}
+
+	return prompts.NewPromptTemplate(
+		l.options.instructionTemplate,
+		[]string{"tools", "tool_choice"},
+	).Format(map[string]any{
+		"tools":       strings.Join(descriptions, "\n"),
+		"tool_choice": choice,
+	})
+}
+
+// toolChoiceSentence turns the value given to llms.WithToolChoice into an
+// extra sentence for the instruction.
+func toolChoiceSentence(toolChoice any) (string, error) {
+	switch choice := toolChoice.(type) {
+	case nil:
+		return "", nil
+	case string:
+		switch choice {
+		case "", "auto", "none":
+			return "", nil
+		case "required", "any":
+			return "\nYou must call at least one tool.", nil
+		}
+		return fmt.Sprintf("\nYou must call the tool %q.", choice), nil
+	}
+
+	// A specific tool, usually given as {"type": "function", "function": {"name": "..."}}.
+	b, err := json.Marshal(toolChoice)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrUnsupportedToolChoice, err)
+	}
+	var ref struct {
+		Name     string `json:"name"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(b, &ref); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedToolChoice, string(b))
+	}
+	name := ref.Function.Name
+	if name == "" {
+		name = ref.Name
+	}
+	if name == "" {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedToolChoice, string(b))
+	}
+	return fmt.Sprintf("\nYou must call the tool %q.", name), nil
+}
+
+// rewriteMessages turns tool calls and tool results into plain text so that
+// every message has at most a single text part.
+func (l *LLM) rewriteMessages(messages []llms.MessageContent) ([]llms.MessageContent, error) {
+	result := make([]llms.MessageContent, 0, len(messages))
+	for _, mc := range messages {
+		var texts []string
+		var others []llms.ContentPart
+		var calls []toolCall
+		rewritten := false
+
+		for _, p := range mc.Parts {
+			switch pt := p.(type) {
+			case llms.TextContent:
+				texts = append(texts, pt.Text)
+			case llms.ToolCall:
+				rewritten = true
+				if pt.FunctionCall == nil {
+					continue
+				}
+				calls = append(calls, toolCall{
+					Name:      pt.FunctionCall.Name,
+					Arguments: argumentsToRaw(pt.FunctionCall.Arguments),
+				})
+			case llms.ToolCallResponse:
+				rewritten = true
+				texts = append(texts, fmt.Sprintf("Result of tool %q:\n%s", pt.Name, pt.Content))
+			default:
+				others = append(others, p)
+			}
+		}
+
+		if len(calls) > 0 {
+			b, err := json.Marshal(toolCallsReply{ToolCalls: calls})
+			if err != nil {
+				return nil, err
+			}
+			texts = append(texts, string(b))
+		}
+
+		role := mc.Role
+		if rewritten && (role == llms.ChatMessageTypeTool || role == llms.ChatMessageTypeFunction) {
+			role = l.options.toolResultRole
+		}
+		if !rewritten && len(texts) < 2 {
+			result = append(result, mc)
+			continue
+		}
+
+		parts := make([]llms.ContentPart, 0, len(others)+1)
+		if len(texts) > 0 {
+			parts = append(parts, llms.TextPart(strings.Join(texts, "\n\n")))
+		}
+		result = append(result, llms.MessageContent{Role: role, Parts: append(parts, others...)})
+	}
+	return result, nil
+}
+
+// injectSystemInstruction appends the instruction to the leading system
+// message, or prepends a new system message if there is none.
+func injectSystemInstruction(messages []llms.MessageContent, instruction string) []llms.MessageContent {
+	if len(messages) > 0 && messages[0].Role == llms.ChatMessageTypeSystem {
+		parts := make([]llms.ContentPart, 0, len(messages[0].Parts)+1)
+		injected := false
+		for _, p := range messages[0].Parts {
+			if tc, ok := p.(llms.TextContent); ok && !injected {
+				p = llms.TextPart(tc.Text + "\n\n" + instruction)
+				injected = true
+			}
+			parts = append(parts, p)
+		}
+		if !injected {
+			parts = append(parts, llms.TextPart(instruction))
+		}
+
+		result := make([]llms.MessageContent, 0, len(messages))
+		result = append(result, llms.MessageContent{Role: llms.ChatMessageTypeSystem, Parts: parts})
+		return append(result, messages[1:]...)
+	}
+
+	result := make([]llms.MessageContent, 0, len(messages)+1)
+	result = append(result, llms.TextParts(llms.ChatMessageTypeSystem, instruction))
+	return append(result, messages...)
+}
+
+type toolCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type toolCallsReply struct {
+	ToolCalls []toolCall `json:"tool_calls"`
+}
+
+// parseToolCalls extracts the tool calls from the model's reply. It returns
+// false if the reply is not a tool call.
+func parseToolCalls(content string) ([]llms.ToolCall, bool) {
+	for _, candidate := range jsonCandidates(content) {
+		var reply struct {
+			toolCallsReply
+			toolCall
+		}
+		if err := json.Unmarshal([]byte(candidate), &reply); err != nil {
+			continue
+		}
+
+		calls := reply.ToolCalls
+		if len(calls) == 0 && reply.Name != "" && reply.Arguments != nil {
+			calls = []toolCall{reply.toolCall}
+		}
+		if len(calls) == 0 {
+			continue
+		}
+
+		result := make([]llms.ToolCall, 0, len(calls))
+		for _, call := range calls {
+			if call.Name == "" {
+				continue
+			}
+			result = append(result, llms.ToolCall{
+				ID:   "call_" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+				Type: "function",
+				FunctionCall: &llms.FunctionCall{
+					Name:      call.Name,
+					Arguments: argumentsToString(call.Arguments),
+				},
+			})
+		}
+		if len(result) > 0 {
+			return result, true
+		}
+	}
+	return nil, false
+}
+
+// jsonCandidates returns the pieces of text that may hold the JSON reply: the
+// whole trimmed text, the contents of fenced code blocks and the span between
+// the first '{' and the last '}'.
+func jsonCandidates(content string) []string {
+	content = strings.TrimSpace(content)
+	candidates := []string{content}
+
+	rest := content
+	for {
+		start := strings.Index(rest, "```")
+		if start < 0 {
+			break
+		}
+		rest = rest[start+3:]
+		end := strings.Index(rest, "```")
+		if end < 0 {
+			break
+		}
+		block := rest[:end]
+		// Drop the info string, e.g. "json".
+		if nl := strings.IndexByte(block, '\n'); nl >= 0 && !strings.HasPrefix(strings.TrimSpace(block[:nl]), "{") {
+			block = block[nl+1:]
+		}
+		candidates = append(candidates, strings.TrimSpace(block))
+		rest = rest[end+3:]
+	}
+
+	if start, end := strings.IndexByte(content, '{'), strings.LastIndexByte(content, '}'); start >= 0 && end > start {
+		candidates = append(candidates, content[start:end+1])
+	}
+	return candidates
+}
+
+// argumentsToString returns the arguments as a JSON string, unquoting them if
+// the model encoded the arguments object as a string.
+func argumentsToString(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return "{}"
+	}
+	var s string
+	if raw[0] == '"' && json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	compact := new(bytes.Buffer)
+	if err := json.Compact(compact, raw); err != nil {
+		return string(raw)
+	}
+	return compact.String()
+}
+
+// argumentsToRaw is the inverse of argumentsToString, falling back to a JSON
+// string if the arguments are not valid JSON.
+func argumentsToRaw(arguments string) json.RawMessage {
+	if json.Valid([]byte(arguments)) {
+		return json.RawMessage(arguments)
+	}
+	b, _ := json.Marshal(arguments) //nolint:errchkjson
+	return b
+}
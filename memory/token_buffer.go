@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/schema"
+)
+
+// ErrUnknownTokenLimit is returned when a token buffer has no token limit and
+// its model does not report a context length.
+var ErrUnknownTokenLimit = errors.New("no token limit set and the model does not report its context length")
+
+// ConversationTokenBuffer for storing conversation memory, pruning the oldest
+// messages once the history exceeds a number of tokens.
+type ConversationTokenBuffer struct {
+	ConversationBuffer
+	LLM llms.Model
+	// MaxTokenLimit is the maximum number of tokens of the history. If it is
+	// zero, the context length the model reports is used. It is capped to that
+	// context length.
+	MaxTokenLimit int
+}
+
+// Statically assert that ConversationTokenBuffer implement the memory interface.
+var _ schema.Memory = &ConversationTokenBuffer{}
+
+// NewConversationTokenBuffer is a function for creating a new token buffer memory.
+func NewConversationTokenBuffer(
+	llm llms.Model,
+	maxTokenLimit int,
+	options ...ConversationBufferOption,
+) *ConversationTokenBuffer {
+	return &ConversationTokenBuffer{
+		LLM:                llm,
+		MaxTokenLimit:      maxTokenLimit,
+		ConversationBuffer: *applyBufferOptions(options...),
+	}
+}
+
+// MemoryVariables uses ConversationBuffer method for memory variables.
+func (tb *ConversationTokenBuffer) MemoryVariables(ctx context.Context) []string {
+	return tb.ConversationBuffer.MemoryVariables(ctx)
+}
+
+// LoadMemoryVariables uses ConversationBuffer method for loading memory variables.
+func (tb *ConversationTokenBuffer) LoadMemoryVariables(
+	ctx context.Context, inputs map[string]any,
+) (map[string]any, error) {
+	return tb.ConversationBuffer.LoadMemoryVariables(ctx, inputs)
+}
+
+// SaveContext uses ConversationBuffer method for saving context and prunes memory buffer if needed.
+func (tb *ConversationTokenBuffer) SaveContext(
+	ctx context.Context,
+	inputValues map[string]any,
+	outputValues map[string]any,
+) error {
+	err := tb.ConversationBuffer.SaveContext(ctx, inputValues, outputValues)
+	if err != nil {
+		return err
+	}
+
+	limit, model, err := tb.tokenLimit(ctx)
+	if err != nil {
+		return err
+	}
+
+	messages, err := tb.ChatHistory.Messages(ctx)
+	if err != nil {
+		return err
+	}
+
+	pruned := false
+	for len(messages) > 0 {
+		tokens, err := tb.countTokens(model, messages)
+		if err != nil {
+			return err
+		}
+		if tokens <= limit {
+			break
+		}
+		messages = messages[1:]
+		pruned = true
+	}
+
+	if pruned {
+		return tb.ChatHistory.SetMessages(ctx, messages)
+	}
+	return nil
+}
+
+// Clear uses ConversationBuffer method for clearing buffer memory.
+func (tb *ConversationTokenBuffer) Clear(ctx context.Context) error {
+	return tb.ConversationBuffer.Clear(ctx)
+}
+
+// tokenLimit returns the token limit of the history and the name of the
+// model, consulting the capabilities of the model.
+func (tb *ConversationTokenBuffer) tokenLimit(ctx context.Context) (int, string, error) {
+	caps, err := llms.GetCapabilities(ctx, tb.LLM)
+	if err != nil && !errors.Is(err, llms.ErrCapabilitiesUnknown) {
+		return 0, "", err
+	}
+
+	limit := tb.MaxTokenLimit
+	if caps.ContextLength > 0 && (limit <= 0 || limit > caps.ContextLength) {
+		limit = caps.ContextLength
+	}
+	if limit <= 0 {
+		return 0, "", fmt.Errorf("%w: %T", ErrUnknownTokenLimit, tb.LLM)
+	}
+	return limit, caps.Model, nil
+}
+
+func (tb *ConversationTokenBuffer) countTokens(model string, messages []llms.ChatMessage) (int, error) {
+	bufferString, err := llms.GetBufferString(messages, tb.HumanPrefix, tb.AIPrefix)
+	if err != nil {
+		return 0, err
+	}
+	return llms.CountTokens(model, bufferString), nil
+}
@@ -0,0 +1,67 @@
+package outputparser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/schema"
+)
+
+// Boolean is an output parser for yes or no answers. The words are matched
+// case-insensitively as whole words, so "Yes, it is." is true.
+type Boolean struct {
+	// TrueStrings are the words meaning true.
+	TrueStrings []string
+	// FalseStrings are the words meaning false.
+	FalseStrings []string
+}
+
+var _ schema.OutputParser[bool] = Boolean{}
+
+// NewBoolean creates a boolean parser accepting yes, y, true and their
+// negations.
+func NewBoolean() Boolean {
+	return Boolean{
+		TrueStrings:  []string{"yes", "y", "true"},
+		FalseStrings: []string{"no", "n", "false"},
+	}
+}
+
+func (p Boolean) GetFormatInstructions() string {
+	return fmt.Sprintf("Your response should be %s or %s, without anything else.",
+		strings.ToUpper(p.first(p.TrueStrings, "yes")), strings.ToUpper(p.first(p.FalseStrings, "no")))
+}
+
+// Parse fails if the text holds both a true and a false word, or neither.
+func (p Boolean) Parse(text string) (bool, error) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	hasTrue := slices.ContainsFunc(words, func(w string) bool { return containsFold(p.TrueStrings, w) })
+	hasFalse := slices.ContainsFunc(words, func(w string) bool { return containsFold(p.FalseStrings, w) })
+	if hasTrue == hasFalse {
+		return false, &ParseError{Text: text, Err: fmt.Errorf("%w: expected one of %s or %s",
+			ErrInvalidBoolean, strings.Join(p.TrueStrings, ", "), strings.Join(p.FalseStrings, ", "))}
+	}
+	return hasTrue, nil
+}
+
+func (p Boolean) ParseWithPrompt(text string, _ llms.PromptValue) (bool, error) {
+	return p.Parse(text)
+}
+
+func (p Boolean) Type() string { return "boolean_parser" }
+
+func (p Boolean) first(values []string, fallback string) string {
+	if len(values) == 0 {
+		return fallback
+	}
+	return values[0]
+}
+
+func containsFold(values []string, s string) bool {
+	return slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, s) })
+}
@@ -0,0 +1,48 @@
+package outputparser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/schema"
+)
+
+// CodeBlock is an output parser returning the contents of a markdown fenced
+// code block.
+type CodeBlock struct {
+	// Language is the language of the block, e.g. "python". If set, the
+	// first block of that language is returned, otherwise the first block.
+	Language string
+}
+
+var _ schema.OutputParser[string] = CodeBlock{}
+
+// NewCodeBlock creates a parser for code blocks of the given language, or of
+// any language if empty.
+func NewCodeBlock(language string) CodeBlock {
+	return CodeBlock{Language: language}
+}
+
+func (p CodeBlock) GetFormatInstructions() string {
+	return fmt.Sprintf("Your response should contain the code in a markdown code block:\n```%s\n...\n```", p.Language)
+}
+
+func (p CodeBlock) Parse(text string) (string, error) {
+	for _, block := range codeBlocks(text) {
+		if p.Language == "" || strings.EqualFold(block.Language, p.Language) {
+			return block.Code, nil
+		}
+	}
+	err := ErrNoCodeBlock
+	if p.Language != "" {
+		err = fmt.Errorf("%w: no %s block", ErrNoCodeBlock, p.Language)
+	}
+	return "", &ParseError{Text: text, Err: err}
+}
+
+func (p CodeBlock) ParseWithPrompt(text string, _ llms.PromptValue) (string, error) {
+	return p.Parse(text)
+}
+
+func (p CodeBlock) Type() string { return "code_block_parser" }
@@ -0,0 +1,45 @@
+package outputparser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/schema"
+)
+
+// Enum is an output parser for answers that are one of a set of values. The
+// output is matched case-insensitively, ignoring surrounding quotes, emphasis
+// and periods, and the value is returned as spelled in Values.
+type Enum struct {
+	Values []string
+}
+
+var _ schema.OutputParser[string] = Enum{}
+
+// NewEnum creates a parser for the given values.
+func NewEnum(values ...string) Enum {
+	return Enum{Values: values}
+}
+
+func (p Enum) GetFormatInstructions() string {
+	return "Select one of the following options: " + strings.Join(p.Values, ", ") +
+		". Respond with the option only."
+}
+
+func (p Enum) Parse(text string) (string, error) {
+	answer := strings.Trim(text, " \t\r\n\"'`*.")
+	for _, value := range p.Values {
+		if strings.EqualFold(answer, value) {
+			return value, nil
+		}
+	}
+	return "", &ParseError{Text: text, Err: fmt.Errorf("%w: %q, must be one of %s",
+		ErrInvalidEnum, strings.TrimSpace(text), strings.Join(p.Values, ", "))}
+}
+
+func (p Enum) ParseWithPrompt(text string, _ llms.PromptValue) (string, error) {
+	return p.Parse(text)
+}
+
+func (p Enum) Type() string { return "enum_parser" }
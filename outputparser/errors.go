@@ -0,0 +1,67 @@
+package outputparser
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoJSON is returned when the output holds no JSON value.
+	ErrNoJSON = errors.New("no JSON value found in output")
+	// ErrInvalidJSON is returned when the JSON value of the output does not
+	// decode into the output type.
+	ErrInvalidJSON = errors.New("invalid JSON value")
+	// ErrMissingField is returned when a required field is missing.
+	ErrMissingField = errors.New("missing required field")
+	// ErrInvalidEnum is returned when a value is not one of the allowed ones.
+	ErrInvalidEnum = errors.New("value not allowed")
+	// ErrNoListItems is returned when the output holds no list item.
+	ErrNoListItems = errors.New("no list items found in output")
+	// ErrInvalidBoolean is returned when the output is neither a yes nor a no,
+	// or both.
+	ErrInvalidBoolean = errors.New("output is not a boolean answer")
+	// ErrNoMatch is returned when the output does not match a regular
+	// expression.
+	ErrNoMatch = errors.New("output does not match the expected format")
+	// ErrNoCodeBlock is returned when the output holds no fenced code block.
+	ErrNoCodeBlock = errors.New("no code block found in output")
+	// ErrUnsupportedType is returned when no JSON schema can be generated for
+	// a type.
+	ErrUnsupportedType = errors.New("unsupported type")
+)
+
+// ParseError is returned when an output cannot be parsed. It holds the output
+// so that it can be shown to a model asked to fix it.
+type ParseError struct {
+	// Text is the output that could not be parsed.
+	Text string
+	// Err is the reason, possibly several joined FieldErrors.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse output: %v", e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// FieldError is an error about one value of a JSON output.
+type FieldError struct {
+	// Path locates the value, e.g. "items[2].name", empty for the whole value.
+	Path string
+	// Err is the error about the value.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,174 @@
+package outputparser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mateors/llmg/callbacks"
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/schema"
+)
+
+const _fixTemplate = `Prompt:
+--------------
+{{.prompt}}
+--------------
+Instructions:
+--------------
+{{.instructions}}
+--------------
+Completion:
+--------------
+{{.completion}}
+--------------
+
+Above, the Completion did not satisfy the constraints given in the Instructions.
+Error:
+--------------
+{{.error}}
+--------------
+
+Please try again. Please only respond with an answer that satisfies the constraints laid out in the Instructions:`
+
+var (
+	// ErrParseAttemptsExceeded is returned when an output could still not be
+	// parsed after the maximum number of attempts.
+	ErrParseAttemptsExceeded = errors.New("output could not be parsed within the maximum number of attempts")
+	// ErrNoPrompt is returned when a Retry parser is used without the prompt
+	// to run again.
+	ErrNoPrompt = errors.New("no prompt to retry")
+)
+
+// Fixing is an output parser that, when its parser fails, asks a model to fix
+// the output given the original prompt, the format instructions, the output
+// and the error, and parses the answer instead, until it succeeds or the
+// maximum number of attempts is reached. Create it with NewFixing.
+type Fixing[T any] struct {
+	Parser schema.OutputParser[T]
+	LLM    llms.Model
+
+	opts retryOptions
+}
+
+var _ schema.ContextOutputParser[any] = Fixing[any]{}
+
+// NewFixing creates a parser fixing the failed outputs of parser with llm.
+func NewFixing[T any](llm llms.Model, parser schema.OutputParser[T], opts ...RetryOption) Fixing[T] {
+	return Fixing[T]{Parser: parser, LLM: llm, opts: newRetryOptions(opts)}
+}
+
+func (p Fixing[T]) GetFormatInstructions() string { return p.Parser.GetFormatInstructions() }
+
+// Parse parses text, fixing it without the original prompt if needed.
+func (p Fixing[T]) Parse(text string) (T, error) {
+	return p.ParseWithPromptContext(context.Background(), text, nil)
+}
+
+func (p Fixing[T]) ParseWithPrompt(text string, prompt llms.PromptValue) (T, error) {
+	return p.ParseWithPromptContext(context.Background(), text, prompt)
+}
+
+func (p Fixing[T]) ParseWithPromptContext(ctx context.Context, text string, prompt llms.PromptValue) (T, error) {
+	return parseWithRetries(ctx, p.Parser, p.opts, text, prompt,
+		func(ctx context.Context, output string, parseErr error) (string, error) {
+			promptText := ""
+			if prompt != nil {
+				promptText = prompt.String()
+			}
+			fix, err := p.opts.fixPrompt.Format(map[string]any{
+				"instructions": p.Parser.GetFormatInstructions(),
+				"prompt":       promptText,
+				"completion":   output,
+				"error":        parseErr.Error(),
+			})
+			if err != nil {
+				return "", err
+			}
+			return llms.GenerateFromSinglePrompt(ctx, p.LLM, fix, p.opts.callOptions...)
+		})
+}
+
+func (p Fixing[T]) Type() string { return "output_fixing_parser" }
+
+// Retry is an output parser that, when its parser fails, runs the original
+// prompt again and parses the new output instead, until it succeeds or the
+// maximum number of attempts is reached. Create it with NewRetry. It needs the
+// prompt, so Parse fails with ErrNoPrompt if the output cannot be parsed.
+type Retry[T any] struct {
+	Parser schema.OutputParser[T]
+	LLM    llms.Model
+
+	opts retryOptions
+}
+
+var _ schema.ContextOutputParser[any] = Retry[any]{}
+
+// NewRetry creates a parser running the prompt again with llm when parser
+// fails.
+func NewRetry[T any](llm llms.Model, parser schema.OutputParser[T], opts ...RetryOption) Retry[T] {
+	return Retry[T]{Parser: parser, LLM: llm, opts: newRetryOptions(opts)}
+}
+
+func (p Retry[T]) GetFormatInstructions() string { return p.Parser.GetFormatInstructions() }
+
+func (p Retry[T]) Parse(text string) (T, error) {
+	return p.ParseWithPromptContext(context.Background(), text, nil)
+}
+
+func (p Retry[T]) ParseWithPrompt(text string, prompt llms.PromptValue) (T, error) {
+	return p.ParseWithPromptContext(context.Background(), text, prompt)
+}
+
+func (p Retry[T]) ParseWithPromptContext(ctx context.Context, text string, prompt llms.PromptValue) (T, error) {
+	return parseWithRetries(ctx, p.Parser, p.opts, text, prompt,
+		func(ctx context.Context, _ string, parseErr error) (string, error) {
+			if prompt == nil {
+				return "", fmt.Errorf("%w: %w", ErrNoPrompt, parseErr)
+			}
+			resp, err := p.LLM.GenerateContent(ctx, llms.ChatMessagesToContent(prompt.Messages()), p.opts.callOptions...)
+			if err != nil {
+				return "", err
+			}
+			if len(resp.Choices) < 1 {
+				return "", llms.ErrEmptyResponse
+			}
+			return resp.Choices[0].Content, nil
+		})
+}
+
+func (p Retry[T]) Type() string { return "retry_parser" }
+
+// parseWithRetries parses text, and while it fails asks next for a new output,
+// at most opts.maxAttempts times. Every attempt is sent to the handlers of ctx
+// implementing callbacks.ParseHandler and to opts.onAttempt.
+func parseWithRetries[T any](
+	ctx context.Context,
+	parser schema.OutputParser[T],
+	opts retryOptions,
+	text string,
+	prompt llms.PromptValue,
+	next func(ctx context.Context, output string, parseErr error) (string, error),
+) (T, error) {
+	output := text
+	for attempt := 1; ; attempt++ {
+		result, err := parseWithPromptContext(ctx, parser, output, prompt)
+		if handler, ok := callbacks.HandlerFromContext(ctx).(callbacks.ParseHandler); ok {
+			handler.HandleParseAttempt(ctx, attempt, output, err)
+		}
+		if opts.onAttempt != nil {
+			opts.onAttempt(ctx, ParseAttempt{Attempt: attempt, Output: output, Err: err})
+		}
+		if err == nil {
+			return result, nil
+		}
+		if attempt > opts.maxAttempts {
+			return result, fmt.Errorf("%w: %d attempts: %w", ErrParseAttemptsExceeded, attempt, err)
+		}
+
+		output, err = next(ctx, output, err)
+		if err != nil {
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package outputparser
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// extractJSON returns the JSON value of an output: the contents of the first
+// fenced code block holding one, the whole output, or the first value found
+// in the surrounding prose. If open is '{' or '[', only objects or arrays are
+// looked for.
+func extractJSON(text string, open byte) (json.RawMessage, bool) {
+	text = strings.TrimSpace(text)
+	for _, block := range codeBlocks(text) {
+		if raw, ok := validJSON(block.Code, open); ok {
+			return raw, true
+		}
+	}
+	if raw, ok := validJSON(text, open); ok {
+		return raw, true
+	}
+
+	for i := range len(text) {
+		c := text[i]
+		if c != '{' && c != '[' || open != 0 && c != open {
+			continue
+		}
+		dec := json.NewDecoder(strings.NewReader(text[i:]))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == nil {
+			return raw, true
+		}
+	}
+	return nil, false
+}
+
+func validJSON(text string, open byte) (json.RawMessage, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" || open != 0 && text[0] != open || !json.Valid([]byte(text)) {
+		return nil, false
+	}
+	return json.RawMessage(text), true
+}
+
+// codeBlock is a fenced code block of a markdown text.
+type codeBlock struct {
+	// Language is the info string of the block, e.g. "json".
+	Language string
+	// Code is the contents of the block.
+	Code string
+}
+
+// codeBlocks returns the fenced code blocks of a markdown text, in order.
+func codeBlocks(text string) []codeBlock {
+	var blocks []codeBlock
+	rest := text
+	for {
+		start := strings.Index(rest, "```")
+		if start < 0 {
+			return blocks
+		}
+		rest = rest[start+3:]
+		end := strings.Index(rest, "```")
+		if end < 0 {
+			return blocks
+		}
+		block := rest[:end]
+		rest = rest[end+3:]
+
+		var language string
+		if nl := strings.IndexByte(block, '\n'); nl >= 0 {
+			info := strings.TrimSpace(block[:nl])
+			if !strings.ContainsAny(info, " {[") {
+				language, block = info, block[nl+1:]
+			}
+		}
+		blocks = append(blocks, codeBlock{
+			Language: language,
+			Code:     strings.TrimRight(block, " \t\r\n"),
+		})
+	}
+}
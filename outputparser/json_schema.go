@@ -0,0 +1,244 @@
+package outputparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONSchema is the subset of JSON Schema generated for Go types and checked
+// by Structured.
+type JSONSchema struct {
+	Type                 string                 `json:"type,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Enum                 []any                  `json:"enum,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	_timeType      = reflect.TypeFor[time.Time]()
+	_marshalerType = reflect.TypeFor[json.Marshaler]()
+)
+
+// JSONSchemaFor generates the JSON schema of the values of a Go type, as
+// encoded by encoding/json.
+//
+// Struct fields are named after their json tag and are required unless they
+// are pointers or tagged omitempty. The description tag sets the description
+// of a field and the enum tag its allowed values, separated by commas:
+//
+//	type Answer struct {
+//		Text       string   `json:"text" description:"the answer"`
+//		Confidence string   `json:"confidence" enum:"low,medium,high"`
+//		Sources    []string `json:"sources,omitempty"`
+//	}
+func JSONSchemaFor(t reflect.Type) (*JSONSchema, error) {
+	return schemaFor(t, map[reflect.Type]bool{})
+}
+
+func schemaFor(t reflect.Type, visiting map[reflect.Type]bool) (*JSONSchema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == _timeType {
+		return &JSONSchema{Type: "string", Format: "date-time"}, nil
+	}
+	if t.Implements(_marshalerType) || reflect.PointerTo(t).Implements(_marshalerType) {
+		// The encoding is up to the type.
+		return &JSONSchema{}, nil
+	}
+
+	switch t.Kind() { //nolint:exhaustive
+	case reflect.String:
+		return &JSONSchema{Type: "string"}, nil
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}, nil
+	case reflect.Interface:
+		return &JSONSchema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Bytes are encoded as base64.
+			return &JSONSchema{Type: "string"}, nil
+		}
+		items, err := schemaFor(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("%w: %s: map keys must be strings", ErrUnsupportedType, t)
+		}
+		values, err := schemaFor(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		if visiting[t] {
+			// A recursive type, leave the nested value unconstrained.
+			return &JSONSchema{Type: "object"}, nil
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		s := &JSONSchema{Type: "object", Properties: map[string]*JSONSchema{}}
+		if err := addFields(s, t, visiting); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, t)
+}
+
+// addFields adds the fields of a struct to s, including the ones of embedded
+// structs.
+func addFields(s *JSONSchema, t reflect.Type, visiting map[reflect.Type]bool) error {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			if err := addFields(s, fieldType, visiting); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fs, err := schemaFor(field.Type, visiting)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		fs.Description = field.Tag.Get("description")
+		if enum := field.Tag.Get("enum"); enum != "" {
+			fs.Enum, err = enumValues(fs.Type, enum)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+		s.Properties[name] = fs
+
+		optional := field.Type.Kind() == reflect.Pointer || strings.Contains(","+opts+",", ",omitempty,")
+		if !optional {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return nil
+}
+
+// enumValues parses the values of an enum tag as values of the given JSON
+// type, the way they are decoded into an any.
+func enumValues(typ, tag string) ([]any, error) {
+	parts := strings.Split(tag, ",")
+	values := make([]any, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		switch typ {
+		case "string":
+			values = append(values, part)
+		case "integer", "number":
+			f, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: enum value %q is not a number", ErrUnsupportedType, part)
+			}
+			values = append(values, f)
+		default:
+			return nil, fmt.Errorf("%w: enum on a value of type %q", ErrUnsupportedType, typ)
+		}
+	}
+	return values, nil
+}
+
+// validate checks the required fields and the enums of a decoded JSON value.
+func (s *JSONSchema) validate(path string, value any) []error {
+	if s == nil || value == nil {
+		return nil
+	}
+
+	var errs []error
+	if len(s.Enum) > 0 && !containsValue(s.Enum, value) {
+		errs = append(errs, &FieldError{Path: path, Err: fmt.Errorf("%w: %v, must be one of %s",
+			ErrInvalidEnum, formatValue(value), formatValues(s.Enum))})
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				errs = append(errs, &FieldError{Path: joinPath(path, name), Err: ErrMissingField})
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(v)) {
+			if property, ok := s.Properties[name]; ok {
+				errs = append(errs, property.validate(joinPath(path, name), v[name])...)
+			} else {
+				errs = append(errs, s.AdditionalProperties.validate(joinPath(path, name), v[name])...)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			errs = append(errs, s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item)...)
+		}
+	}
+	return errs
+}
+
+func containsValue(values []any, value any) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func formatValue(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func formatValues(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = formatValue(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
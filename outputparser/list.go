@@ -0,0 +1,92 @@
+package outputparser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/schema"
+)
+
+// CommaSeparatedList is an output parser splitting a comma separated list.
+type CommaSeparatedList struct{}
+
+var _ schema.OutputParser[[]string] = CommaSeparatedList{}
+
+// NewCommaSeparatedList creates a comma separated list parser.
+func NewCommaSeparatedList() CommaSeparatedList { return CommaSeparatedList{} }
+
+func (p CommaSeparatedList) GetFormatInstructions() string {
+	return "Your response should be a list of comma separated values, eg: `foo, bar, baz`"
+}
+
+// Parse returns the trimmed values of the list, without empty ones.
+func (p CommaSeparatedList) Parse(text string) ([]string, error) {
+	var values []string
+	for _, value := range strings.Split(strings.TrimSuffix(strings.TrimSpace(text), "."), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return nil, &ParseError{Text: text, Err: ErrNoListItems}
+	}
+	return values, nil
+}
+
+func (p CommaSeparatedList) ParseWithPrompt(text string, _ llms.PromptValue) ([]string, error) {
+	return p.Parse(text)
+}
+
+func (p CommaSeparatedList) Type() string { return "comma_separated_list_parser" }
+
+var _listItem = regexp.MustCompile(`^\s*(?:[-*+•]|\d+[.)])\s+(.+?)\s*$`)
+
+// List is an output parser returning the items of a numbered or bulleted
+// list. Both kinds of items are recognized whatever the instructions asked
+// for, and the lines that are not items, e.g. an introduction, are ignored.
+type List struct {
+	// Numbered sets whether the format instructions ask for a numbered list
+	// rather than a bulleted one.
+	Numbered bool
+}
+
+var _ schema.OutputParser[[]string] = List{}
+
+// NewNumberedList creates a parser of numbered lists: "1. foo".
+func NewNumberedList() List { return List{Numbered: true} }
+
+// NewMarkdownList creates a parser of bulleted lists: "- foo".
+func NewMarkdownList() List { return List{} }
+
+func (p List) GetFormatInstructions() string {
+	if p.Numbered {
+		return "Your response should be a numbered list with each item on a new line. For example:\n\n1. foo\n\n2. bar\n\n3. baz"
+	}
+	return "Your response should be a markdown list, each item on a new line starting with `- `. For example:\n\n- foo\n- bar\n- baz"
+}
+
+// Parse returns the text of the list items.
+func (p List) Parse(text string) ([]string, error) {
+	var items []string
+	for _, line := range strings.Split(text, "\n") {
+		if m := _listItem.FindStringSubmatch(line); m != nil {
+			items = append(items, m[1])
+		}
+	}
+	if len(items) == 0 {
+		return nil, &ParseError{Text: text, Err: ErrNoListItems}
+	}
+	return items, nil
+}
+
+func (p List) ParseWithPrompt(text string, _ llms.PromptValue) ([]string, error) {
+	return p.Parse(text)
+}
+
+func (p List) Type() string {
+	if p.Numbered {
+		return "numbered_list_parser"
+	}
+	return "markdown_list_parser"
+}
@@ -0,0 +1,74 @@
+package outputparser
+
+import (
+	"context"
+
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/prompts"
+)
+
+const _defaultMaxAttempts = 2
+
+// ParseAttempt is one parse of an output by a Fixing or Retry parser.
+type ParseAttempt struct {
+	// Attempt is the number of the attempt, 1 for the original output.
+	Attempt int
+	// Output is the output parsed.
+	Output string
+	// Err is the parse error, nil if the output was parsed.
+	Err error
+}
+
+// RetryOption is a function that configures a Fixing or Retry parser.
+type RetryOption func(*retryOptions)
+
+type retryOptions struct {
+	maxAttempts int
+	onAttempt   func(ctx context.Context, attempt ParseAttempt)
+	callOptions []llms.CallOption
+	fixPrompt   prompts.PromptTemplate
+}
+
+// WithMaxAttempts sets the maximum number of times the model is called for a
+// new output. Defaults to 2.
+func WithMaxAttempts(n int) RetryOption {
+	return func(o *retryOptions) {
+		o.maxAttempts = n
+	}
+}
+
+// WithAttemptCallback sets a function called after every parse, including
+// the parse of the original output. The attempts are also sent to the
+// handlers of the context implementing callbacks.ParseHandler.
+func WithAttemptCallback(fn func(ctx context.Context, attempt ParseAttempt)) RetryOption {
+	return func(o *retryOptions) {
+		o.onAttempt = fn
+	}
+}
+
+// WithCallOptions sets the options of the model calls.
+func WithCallOptions(opts ...llms.CallOption) RetryOption {
+	return func(o *retryOptions) {
+		o.callOptions = opts
+	}
+}
+
+// WithFixPrompt sets the prompt asking a Fixing parser's model to fix an
+// output. It is given the instructions, prompt, completion and error
+// variables. Defaults to a prompt like the one of LangChain.
+func WithFixPrompt(prompt prompts.PromptTemplate) RetryOption {
+	return func(o *retryOptions) {
+		o.fixPrompt = prompt
+	}
+}
+
+func newRetryOptions(opts []RetryOption) retryOptions {
+	o := retryOptions{
+		maxAttempts: _defaultMaxAttempts,
+		fixPrompt:   prompts.NewPromptTemplate(_fixTemplate, []string{"instructions", "prompt", "completion", "error"}),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,312 @@
+package outputparser
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// ParsePartialJSON completes the JSON value at the start of a truncated text,
+// e.g. the output of a model streamed so far, so that it can be decoded. Open
+// strings are closed and open arrays and objects are ended, while trailing
+// keys without value, partial literals, numbers and escapes, which may still
+// grow, are dropped: `{"a": [1, 2], "b": "hel` gives `{"a": [1, 2], "b": "hel"}`.
+// The text after a complete value is ignored. It returns false if there is no
+// value yet.
+func ParsePartialJSON(text string) (json.RawMessage, bool) {
+	s := newPartialScanner()
+	s.scan(text)
+	return s.result(text)
+}
+
+type partialFrame struct {
+	kind      byte
+	expectKey bool
+}
+
+// partialScanner tracks the containers open at each point of a text, and the
+// last point at which the text can be cut and closed into a valid value. The
+// text may grow between calls to scan, only the new bytes are scanned.
+type partialScanner struct {
+	scanned   int
+	stack     []partialFrame
+	inString  bool
+	isKey     bool
+	escaped   bool
+	hexLeft   int // hex digits missing in the \u escape starting at escape
+	escape    int
+	token     int // start of the literal or number being scanned, or -1
+	safeLen   int
+	safeStack []byte
+	hasSafe   bool
+	end       int // length of the complete value, once found
+	invalid   bool
+}
+
+func newPartialScanner() *partialScanner {
+	return &partialScanner{token: -1}
+}
+
+func (s *partialScanner) markSafe(n int) {
+	s.safeLen = n
+	s.safeStack = s.stackKinds()
+	s.hasSafe = true
+}
+
+// scan continues scanning text, which must start with the text given to the
+// previous calls.
+func (s *partialScanner) scan(text string) { //nolint:cyclop,funlen
+	for i := s.scanned; i < len(text) && s.end == 0 && !s.invalid; i++ {
+		s.scanned = i + 1
+		c := text[i]
+		if s.inString {
+			switch {
+			case s.hexLeft > 0:
+				s.hexLeft--
+				if !isHex(c) {
+					s.hexLeft = 0
+				}
+			case s.escaped:
+				s.escaped = false
+				if c == 'u' {
+					s.hexLeft, s.escape = 4, i-1
+				}
+			case c == '\\':
+				s.escaped = true
+			case c == '"':
+				s.inString = false
+				switch {
+				case len(s.stack) == 0:
+					s.end = i + 1
+				case !s.isKey:
+					s.markSafe(i + 1)
+				}
+			}
+			continue
+		}
+
+		if s.token >= 0 {
+			if !isDelimiter(c) {
+				continue
+			}
+			s.endToken(text[s.token:i], i)
+			s.token = -1
+			if s.end != 0 || s.invalid {
+				return
+			}
+		}
+
+		switch c {
+		case '{', '[':
+			s.stack = append(s.stack, partialFrame{kind: c, expectKey: c == '{'})
+			s.markSafe(i + 1)
+		case '}', ']':
+			if len(s.stack) == 0 {
+				s.invalid = true
+				return
+			}
+			s.stack = s.stack[:len(s.stack)-1]
+			if len(s.stack) == 0 {
+				s.end = i + 1
+				return
+			}
+			s.markSafe(i + 1)
+		case '"':
+			s.inString = true
+			s.isKey = len(s.stack) > 0 && s.top().expectKey
+		case ':':
+			if len(s.stack) > 0 {
+				s.top().expectKey = false
+			}
+		case ',':
+			if len(s.stack) > 0 && s.top().kind == '{' {
+				s.top().expectKey = true
+			}
+		case ' ', '\t', '\r', '\n':
+		default:
+			// Keys are strings, and other values literals or numbers: this is
+			// not JSON, e.g. a placeholder in the prose before the value.
+			if len(s.stack) > 0 && s.top().expectKey || !strings.ContainsRune("-0123456789tfn", rune(c)) {
+				s.invalid = true
+				return
+			}
+			s.token = i
+		}
+	}
+}
+
+// endToken handles a literal or number ending at n.
+func (s *partialScanner) endToken(token string, n int) {
+	if !json.Valid([]byte(token)) {
+		s.invalid = true
+		return
+	}
+	if len(s.stack) == 0 {
+		s.end = n
+		return
+	}
+	s.markSafe(n)
+}
+
+// result returns the value completed from the text scanned so far.
+func (s *partialScanner) result(text string) (json.RawMessage, bool) {
+	switch {
+	case s.invalid:
+		return nil, false
+	case s.end != 0:
+		return json.RawMessage(text[:s.end]), true
+	case s.inString && !s.isKey:
+		value := text
+		switch {
+		case s.escaped:
+			value = value[:len(value)-1]
+		case s.hexLeft > 0:
+			value = value[:s.escape]
+		}
+		return json.RawMessage(value + `"` + closers(s.stackKinds())), true
+	case s.token >= 0 && isLiteral(text[s.token:]):
+		// Unlike numbers, the literals cannot grow any more.
+		if len(s.stack) == 0 {
+			return json.RawMessage(text[s.token:]), true
+		}
+		return json.RawMessage(text + closers(s.stackKinds())), true
+	}
+	if !s.hasSafe {
+		return nil, false
+	}
+	return json.RawMessage(text[:s.safeLen] + closers(s.safeStack)), true
+}
+
+func isLiteral(token string) bool {
+	return token == "true" || token == "false" || token == "null"
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func isDelimiter(c byte) bool {
+	return strings.IndexByte(" \t\r\n,:]}", c) >= 0
+}
+
+func (s *partialScanner) top() *partialFrame {
+	return &s.stack[len(s.stack)-1]
+}
+
+func (s *partialScanner) stackKinds() []byte {
+	kinds := make([]byte, len(s.stack))
+	for i, f := range s.stack {
+		kinds[i] = f.kind
+	}
+	return kinds
+}
+
+func closers(kinds []byte) string {
+	var b strings.Builder
+	for i := len(kinds) - 1; i >= 0; i-- {
+		if kinds[i] == '{' {
+			b.WriteByte('}')
+		} else {
+			b.WriteByte(']')
+		}
+	}
+	return b.String()
+}
+
+// PartialJSON incrementally parses a JSON value streamed in chunks, e.g. by a
+// streaming function, giving after every chunk the value completed so far,
+// see ParsePartialJSON. The text before the value, e.g. an introduction or
+// the opening of a code block, is skipped, even if it holds braces that turn
+// out not to start a JSON value. Each chunk is scanned once, unless such a
+// false start has to be scanned again. It is safe for concurrent use.
+type PartialJSON struct {
+	mu       sync.Mutex
+	buf      strings.Builder
+	open     byte
+	searched int
+	start    int
+	scanner  *partialScanner // nil until the start of the value is found
+	last     string
+}
+
+// NewPartialJSON creates a partial parser of JSON objects or arrays.
+func NewPartialJSON() *PartialJSON {
+	return &PartialJSON{}
+}
+
+// NewPartialJSONObject creates a partial parser of JSON objects only, so that
+// a '[' in the text before the object is not taken for its start.
+func NewPartialJSONObject() *PartialJSON {
+	return &PartialJSON{open: '{'}
+}
+
+// Write adds a chunk and returns the value completed so far. It returns false
+// if there is no value yet or if the value did not change with the chunk.
+func (p *PartialJSON) Write(chunk []byte) (json.RawMessage, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf.Write(chunk)
+	text := p.buf.String()
+	if p.scanner != nil && p.scanner.end != 0 {
+		// The value is complete, the rest of the text is ignored.
+		return nil, false
+	}
+
+	var raw json.RawMessage
+	for {
+		if p.scanner == nil {
+			start := strings.IndexAny(text[p.searched:], "{[")
+			if p.open != 0 {
+				start = strings.IndexByte(text[p.searched:], p.open)
+			}
+			if start < 0 {
+				p.searched = len(text)
+				return nil, false
+			}
+			p.start = p.searched + start
+			p.scanner = newPartialScanner()
+		}
+		p.scanner.scan(text[p.start:])
+		var ok bool
+		raw, ok = p.scanner.result(text[p.start:])
+		if !p.scanner.invalid && (p.scanner.end == 0 || json.Valid(raw)) {
+			if !ok {
+				return nil, false
+			}
+			break
+		}
+		// A false start, look for the value after it.
+		p.searched, p.scanner = p.start+1, nil
+	}
+	if string(raw) == p.last {
+		return nil, false
+	}
+	p.last = string(raw)
+	return raw, true
+}
+
+// Text returns the text written so far.
+func (p *PartialJSON) Text() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.buf.String()
+}
+
+// StreamingFunc returns a streaming function decoding the value completed so
+// far into an any and passing it to fn every time it changes. An error of fn
+// stops the streaming.
+func (p *PartialJSON) StreamingFunc(fn func(ctx context.Context, value any) error) func(context.Context, []byte) error {
+	return func(ctx context.Context, chunk []byte) error {
+		raw, ok := p.Write(chunk)
+		if !ok {
+			return nil
+		}
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil //nolint:nilerr
+		}
+		return fn(ctx, value)
+	}
+}
@@ -0,0 +1,89 @@
+package outputparser
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParsePartialJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		text string
+		want string
+		ok   bool
+	}{
+		{"empty", "", "", false},
+		{"open object", `{`, `{}`, true},
+		{"open string", `{"a": [1, 2], "b": "hel`, `{"a": [1, 2], "b": "hel"}`, true},
+		{"key without value", `{"a": 1, "b`, `{"a": 1}`, true},
+		{"partial number", `{"a": 1, "b": 12`, `{"a": 1}`, true},
+		{"literal", `{"a": [true`, `{"a": [true]}`, true},
+		{"trailing backslash", `{"a": "x\`, `{"a": "x"}`, true},
+		{"partial unicode escape", `{"a": "x\u00`, `{"a": "x"}`, true},
+		{"escaped backslash before u", `{"a": "x\\u00`, `{"a": "x\\u00"}`, true},
+		{"complete unicode escape", `{"a": "x\u00e9`, `{"a": "x\u00e9"}`, true},
+		{"text after value", `{"a": 1} and more`, `{"a": 1}`, true},
+		{"top level literal", `null`, `null`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, ok := ParsePartialJSON(tt.text)
+			if ok != tt.ok || string(got) != tt.want {
+				t.Fatalf("ParsePartialJSON(%q) = %q, %v, want %q, %v", tt.text, got, ok, tt.want, tt.ok)
+			}
+			if ok && !json.Valid(got) {
+				t.Fatalf("ParsePartialJSON(%q) = %q, not valid JSON", tt.text, got)
+			}
+		})
+	}
+}
+
+func TestPartialJSONWrite(t *testing.T) {
+	t.Parallel()
+
+	text := "Here it is:\n```json\n" + `{"name": "x\\u00e9", "tags": ["a", "b"], "n": 42, "ok": true}` + "\n```"
+	p := NewPartialJSONObject()
+	var last json.RawMessage
+	for i := range len(text) {
+		if raw, ok := p.Write([]byte{text[i]}); ok {
+			if !json.Valid(raw) {
+				t.Fatalf("Write after %q gave invalid JSON %q", text[:i+1], raw)
+			}
+			last = raw
+		}
+	}
+	want := `{"name": "x\\u00e9", "tags": ["a", "b"], "n": 42, "ok": true}`
+	if string(last) != want {
+		t.Fatalf("last value = %q, want %q", last, want)
+	}
+}
+
+func TestPartialJSONWriteSkipsBracesInProse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`Use {name} here: {"a": 1}`, `{"a": 1}`},
+		{`Fill in {a, b} and [x] as {"a": [1, 2]} says`, `{"a": [1, 2]}`},
+	}
+	for _, tt := range tests {
+		p := NewPartialJSON()
+		var last json.RawMessage
+		for i := range len(tt.text) {
+			if raw, ok := p.Write([]byte{tt.text[i]}); ok {
+				if !json.Valid(raw) {
+					t.Fatalf("Write after %q gave invalid JSON %q", tt.text[:i+1], raw)
+				}
+				last = raw
+			}
+		}
+		if string(last) != tt.want {
+			t.Fatalf("last value of %q = %q, want %q", tt.text, last, tt.want)
+		}
+	}
+}
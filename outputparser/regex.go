@@ -0,0 +1,59 @@
+package outputparser
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/schema"
+)
+
+// Regex is an output parser returning the named groups of the first match of
+// a regular expression, by name.
+type Regex struct {
+	Expression *regexp.Regexp
+	// Instructions are the format instructions. Defaults to asking for an
+	// output matching the expression.
+	Instructions string
+}
+
+var _ schema.OutputParser[map[string]string] = Regex{}
+
+// NewRegex creates a parser for a regular expression with named groups, e.g.
+// `Action: (?P<action>.*)\nInput: (?P<input>.*)`.
+func NewRegex(expression string) (Regex, error) {
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return Regex{}, err
+	}
+	return Regex{Expression: re}, nil
+}
+
+func (p Regex) GetFormatInstructions() string {
+	if p.Instructions != "" {
+		return p.Instructions
+	}
+	return fmt.Sprintf("Your response should match the following regular expression: %s", p.Expression)
+}
+
+// Parse returns the named groups of the first match. Groups that did not
+// participate in the match are empty.
+func (p Regex) Parse(text string) (map[string]string, error) {
+	match := p.Expression.FindStringSubmatch(text)
+	if match == nil {
+		return nil, &ParseError{Text: text, Err: fmt.Errorf("%w: %s", ErrNoMatch, p.Expression)}
+	}
+	result := make(map[string]string)
+	for i, name := range p.Expression.SubexpNames() {
+		if name != "" {
+			result[name] = match[i]
+		}
+	}
+	return result, nil
+}
+
+func (p Regex) ParseWithPrompt(text string, _ llms.PromptValue) (map[string]string, error) {
+	return p.Parse(text)
+}
+
+func (p Regex) Type() string { return "regex_parser" }
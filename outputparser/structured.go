@@ -0,0 +1,192 @@
+package outputparser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/schema"
+)
+
+const _structuredFormatInstructions = "The output should be formatted as a JSON instance that conforms to the JSON schema below.\n\n" + //nolint:lll
+	"As an example, for the schema {\"properties\": {\"foo\": {\"description\": \"a list of strings\", \"type\": \"array\", \"items\": {\"type\": \"string\"}}}, \"required\": [\"foo\"]}\n" + //nolint:lll
+	"the object {\"foo\": [\"bar\", \"baz\"]} is a well-formatted instance of the schema. The object {\"properties\": {\"foo\": [\"bar\", \"baz\"]}} is not well-formatted.\n\n" + //nolint:lll
+	"Here is the output schema:\n```json\n%s\n```\n" +
+	"Respond with the JSON instance in a ```json code block, without any other text."
+
+const _anyJSONFormatInstructions = "Respond with a JSON value in a ```json code block, without any other text."
+
+// Structured is an output parser decoding the JSON output of a model into a
+// value of type T. The format instructions give the JSON schema of T, see
+// JSONSchemaFor. The JSON value is taken from a fenced code block or from the
+// surrounding prose, and the required fields and enums are checked before
+// decoding. Errors are *ParseError. The zero value has no schema: it accepts
+// any JSON value that decodes into T.
+type Structured[T any] struct {
+	schema *JSONSchema
+}
+
+var _ schema.OutputParser[any] = Structured[any]{}
+
+// NewStructured creates an output parser for values of type T. It fails if no
+// JSON schema can be generated for T.
+func NewStructured[T any]() (Structured[T], error) {
+	s, err := JSONSchemaFor(reflect.TypeFor[T]())
+	if err != nil {
+		return Structured[T]{}, err
+	}
+	return Structured[T]{schema: s}, nil
+}
+
+// Schema returns the JSON schema of T, nil for the zero value.
+func (p Structured[T]) Schema() *JSONSchema {
+	return p.schema
+}
+
+// GetFormatInstructions returns instructions giving the JSON schema of T.
+func (p Structured[T]) GetFormatInstructions() string {
+	if p.schema == nil {
+		return _anyJSONFormatInstructions
+	}
+	b, err := json.MarshalIndent(p.schema, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf(_structuredFormatInstructions, b)
+}
+
+// Parse extracts, checks and decodes the JSON value of text.
+func (p Structured[T]) Parse(text string) (T, error) {
+	var result T
+
+	raw, ok := extractJSON(text, p.open())
+	if !ok {
+		return result, &ParseError{Text: text, Err: ErrNoJSON}
+	}
+
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return result, &ParseError{Text: text, Err: fmt.Errorf("%w: %w", ErrInvalidJSON, err)}
+	}
+	if errs := p.schema.validate("", value); len(errs) > 0 {
+		return result, &ParseError{Text: text, Err: errors.Join(errs...)}
+	}
+
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return result, &ParseError{Text: text, Err: decodeError(err)}
+	}
+	return result, nil
+}
+
+// ParsePartial decodes the JSON value of a truncated output, e.g. the output
+// streamed so far, into a partially populated T, see ParsePartialJSON. The
+// required fields and enums are not checked. It returns false if there is no
+// value yet.
+func (p Structured[T]) ParsePartial(text string) (T, bool) {
+	var result T
+	raw, ok := (&PartialJSON{open: p.open()}).Write([]byte(text))
+	if !ok || json.Unmarshal(raw, &result) != nil {
+		return result, false
+	}
+	return result, true
+}
+
+// StreamingFunc returns a streaming function passing to fn a partially
+// populated T every time a chunk completes more of it. An error of fn stops
+// the streaming. The returned function accumulates the chunks, so a new one
+// is needed for every generation.
+func (p Structured[T]) StreamingFunc(fn func(ctx context.Context, partial T) error) func(context.Context, []byte) error {
+	partial := &PartialJSON{open: p.open()}
+	return func(ctx context.Context, chunk []byte) error {
+		raw, ok := partial.Write(chunk)
+		if !ok {
+			return nil
+		}
+		var result T
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil //nolint:nilerr
+		}
+		return fn(ctx, result)
+	}
+}
+
+// ParseWithPrompt does the same as Parse.
+func (p Structured[T]) ParseWithPrompt(text string, _ llms.PromptValue) (T, error) {
+	return p.Parse(text)
+}
+
+func (p Structured[T]) Type() string { return "structured_parser" }
+
+// open returns the first character of the JSON values of T, or 0 if they are
+// not objects or arrays or if there is no schema.
+func (p Structured[T]) open() byte {
+	if p.schema == nil {
+		return 0
+	}
+	switch p.schema.Type {
+	case "object":
+		return '{'
+	case "array":
+		return '['
+	}
+	return 0
+}
+
+// decodeError returns a FieldError locating a value of the wrong type.
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &FieldError{
+			Path: strings.TrimPrefix(typeErr.Field, "."),
+			Err:  fmt.Errorf("%w: got %s, want %s", ErrInvalidJSON, typeErr.Value, typeErr.Type),
+		}
+	}
+	return fmt.Errorf("%w: %w", ErrInvalidJSON, err)
+}
+
+// Any is an adapter returning the values of a typed output parser as any, so
+// that it can be used by chains, e.g. as LLMChain.OutputParser.
+type Any[T any] struct {
+	Parser schema.OutputParser[T]
+}
+
+var _ schema.ContextOutputParser[any] = Any[any]{}
+
+// NewAny wraps a typed output parser.
+func NewAny[T any](parser schema.OutputParser[T]) Any[T] {
+	return Any[T]{Parser: parser}
+}
+
+func (p Any[T]) GetFormatInstructions() string { return p.Parser.GetFormatInstructions() }
+
+func (p Any[T]) Parse(text string) (any, error) {
+	return p.Parser.Parse(text)
+}
+
+func (p Any[T]) ParseWithPrompt(text string, prompt llms.PromptValue) (any, error) {
+	return p.Parser.ParseWithPrompt(text, prompt)
+}
+
+// ParseWithPromptContext uses the context if the wrapped parser can.
+func (p Any[T]) ParseWithPromptContext(ctx context.Context, text string, prompt llms.PromptValue) (any, error) {
+	return parseWithPromptContext(ctx, p.Parser, text, prompt)
+}
+
+func (p Any[T]) Type() string { return p.Parser.Type() }
+
+// parseWithPromptContext parses with the context if the parser can use one.
+func parseWithPromptContext[T any](
+	ctx context.Context,
+	parser schema.OutputParser[T],
+	text string,
+	prompt llms.PromptValue,
+) (T, error) {
+	if p, ok := parser.(schema.ContextOutputParser[T]); ok {
+		return p.ParseWithPromptContext(ctx, text, prompt)
+	}
+	return parser.ParseWithPrompt(text, prompt)
+}
@@ -0,0 +1,77 @@
+package outputparser
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/schema"
+)
+
+// XMLTags is an output parser returning the contents of XML tags, e.g.
+// "<thinking>...</thinking><answer>...</answer>", by tag name. The text
+// around the tags is ignored.
+type XMLTags struct {
+	// Tags are the expected tags. They are all required unless listed in
+	// Optional.
+	Tags []string
+	// Optional are the tags that may be missing.
+	Optional []string
+}
+
+var _ schema.OutputParser[map[string]string] = XMLTags{}
+
+// NewXMLTags creates a parser for the given tags, all required.
+func NewXMLTags(tags ...string) XMLTags {
+	return XMLTags{Tags: tags}
+}
+
+func (p XMLTags) GetFormatInstructions() string {
+	var b strings.Builder
+	b.WriteString("Your response should wrap each part of the answer in XML tags, as follows:\n")
+	for _, tag := range p.Tags {
+		fmt.Fprintf(&b, "<%s>...</%s>\n", tag, tag)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Parse returns the trimmed contents of the first occurrence of every tag.
+// Entities are unescaped unless the contents are a CDATA section, which is
+// returned as is.
+func (p XMLTags) Parse(text string) (map[string]string, error) {
+	result := make(map[string]string, len(p.Tags))
+	var errs []error
+	for _, tag := range p.Tags {
+		re := regexp.MustCompile(`(?s)<` + regexp.QuoteMeta(tag) + `(?:\s[^>]*)?>(.*?)</` + regexp.QuoteMeta(tag) + `\s*>`)
+		match := re.FindStringSubmatch(text)
+		if match == nil {
+			if !containsFold(p.Optional, tag) {
+				errs = append(errs, &FieldError{Path: tag, Err: ErrMissingField})
+			}
+			continue
+		}
+		result[tag] = xmlText(strings.TrimSpace(match[1]))
+	}
+	if len(errs) > 0 {
+		return nil, &ParseError{Text: text, Err: errors.Join(errs...)}
+	}
+	return result, nil
+}
+
+func (p XMLTags) ParseWithPrompt(text string, _ llms.PromptValue) (map[string]string, error) {
+	return p.Parse(text)
+}
+
+func (p XMLTags) Type() string { return "xml_tags_parser" }
+
+func xmlText(s string) string {
+	if cdata, ok := strings.CutPrefix(s, "<![CDATA["); ok {
+		if cdata, ok = strings.CutSuffix(cdata, "]]>"); ok {
+			return cdata
+		}
+	}
+	return html.UnescapeString(s)
+}
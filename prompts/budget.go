@@ -0,0 +1,272 @@
+package prompts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/mateors/llmg/llms"
+)
+
+var (
+	// ErrPromptExceedsBudget is returned when a prompt does not fit its token
+	// budget even with every budgeted variable reduced.
+	ErrPromptExceedsBudget = errors.New("prompt exceeds the token budget")
+	// ErrInvalidBudgetedValue is returned when the value of a budgeted
+	// variable does not suit its strategy.
+	ErrInvalidBudgetedValue = errors.New("invalid value for budgeted variable")
+)
+
+// Tokenizer counts the tokens of a text.
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// TokenizerFunc is an adapter to allow the use of ordinary functions as
+// tokenizers.
+type TokenizerFunc func(text string) int
+
+// CountTokens calls f(text).
+func (f TokenizerFunc) CountTokens(text string) int {
+	return f(text)
+}
+
+// ModelTokenizer returns a tokenizer counting tokens like the model does, see
+// llms.CountTokens.
+func ModelTokenizer(model string) Tokenizer { //nolint:ireturn
+	return TokenizerFunc(func(text string) int {
+		return llms.CountTokens(model, text)
+	})
+}
+
+// TruncationStrategy is how a budgeted variable is reduced.
+type TruncationStrategy string
+
+const (
+	// TruncationDropHead drops the first items of a list, e.g. the oldest
+	// messages of a history.
+	TruncationDropHead TruncationStrategy = "drop_head"
+	// TruncationDropTail drops the last items of a list, e.g. the least
+	// relevant retrieved documents.
+	TruncationDropTail TruncationStrategy = "drop_tail"
+	// TruncationTruncateHead cuts the beginning of a text, keeping its end.
+	TruncationTruncateHead TruncationStrategy = "truncate_head"
+	// TruncationTruncateTail cuts the end of a text, keeping its beginning.
+	TruncationTruncateTail TruncationStrategy = "truncate_tail"
+	// TruncationSummarize replaces a text by a summary, see
+	// BudgetedVariable.Summarize.
+	TruncationSummarize TruncationStrategy = "summarize"
+)
+
+// BudgetedVariable is a variable of a budgeted prompt that can be reduced to
+// fit the budget.
+type BudgetedVariable struct {
+	// Name is the name of the variable.
+	Name string
+	// Priority orders the reductions: variables of lower priority are reduced
+	// first, and variables of higher priority only if that is not enough.
+	Priority int
+	// Strategy is how the variable is reduced. Drop strategies apply to
+	// slices, the other ones to strings.
+	Strategy TruncationStrategy
+	// Summarize returns a summary of text of at most maxTokens tokens. It is
+	// required by TruncationSummarize. A summary that is still too long is cut
+	// at its end.
+	Summarize func(text string, maxTokens int) (string, error)
+}
+
+// BudgetedPromptTemplate is a prompt template whose rendered prompt fits in a
+// number of tokens: while the prompt is too long, the budgeted variables are
+// reduced, the ones of lowest priority first.
+type BudgetedPromptTemplate struct {
+	// Prompt is the prompt template rendered.
+	Prompt FormatPrompter
+	// MaxTokens is the maximum number of tokens of the rendered prompt.
+	MaxTokens int
+	// Variables are the variables that can be reduced.
+	Variables []BudgetedVariable
+	// Tokenizer counts the tokens of the rendered prompt. Defaults to
+	// ModelTokenizer("").
+	Tokenizer Tokenizer
+}
+
+var (
+	_ Formatter             = BudgetedPromptTemplate{}
+	_ ContextFormatPrompter = BudgetedPromptTemplate{}
+)
+
+// NewBudgetedPromptTemplate creates a prompt template whose rendered prompt
+// fits in maxTokens tokens.
+func NewBudgetedPromptTemplate(
+	prompt FormatPrompter,
+	maxTokens int,
+	variables ...BudgetedVariable,
+) BudgetedPromptTemplate {
+	return BudgetedPromptTemplate{
+		Prompt:    prompt,
+		MaxTokens: maxTokens,
+		Variables: variables,
+		Tokenizer: ModelTokenizer(""),
+	}
+}
+
+// Format renders the prompt within the budget and returns it as a string.
+func (p BudgetedPromptTemplate) Format(values map[string]any) (string, error) {
+	value, err := p.FormatPrompt(values)
+	if err != nil {
+		return "", err
+	}
+	return value.String(), nil
+}
+
+// FormatPrompt renders the prompt, reducing the budgeted variables until it
+// fits in the budget. It fails with ErrPromptExceedsBudget if it does not
+// fit once they are all reduced to nothing.
+func (p BudgetedPromptTemplate) FormatPrompt(values map[string]any) (llms.PromptValue, error) { //nolint:ireturn
+	return p.FormatPromptContext(context.Background(), values)
+}
+
+// FormatPromptContext does the same as FormatPrompt, passing ctx to the
+// prompt if it uses one.
+func (p BudgetedPromptTemplate) FormatPromptContext(ctx context.Context, values map[string]any) (llms.PromptValue, error) { //nolint:ireturn,lll
+	r := budgetRenderer{ctx: ctx, prompt: p, values: make(map[string]any, len(values))}
+	for k, v := range values {
+		r.values[k] = v
+	}
+
+	value, tokens, err := r.render()
+	if err != nil || tokens <= p.MaxTokens {
+		return value, err
+	}
+
+	variables := slices.Clone(p.Variables)
+	slices.SortStableFunc(variables, func(a, b BudgetedVariable) int { return a.Priority - b.Priority })
+	for _, v := range variables {
+		if _, ok := r.values[v.Name]; !ok {
+			continue
+		}
+		value, tokens, err = r.reduce(v, tokens)
+		if err != nil || tokens <= p.MaxTokens {
+			return value, err
+		}
+	}
+	return nil, fmt.Errorf("%w: %d tokens, budget of %d", ErrPromptExceedsBudget, tokens, p.MaxTokens)
+}
+
+// GetInputVariables returns the input variables of the prompt.
+func (p BudgetedPromptTemplate) GetInputVariables() []string {
+	return p.Prompt.GetInputVariables()
+}
+
+type budgetRenderer struct {
+	ctx    context.Context //nolint:containedctx
+	prompt BudgetedPromptTemplate
+	values map[string]any
+}
+
+func (r budgetRenderer) render() (llms.PromptValue, int, error) {
+	value, err := FormatPromptContext(r.ctx, r.prompt.Prompt, r.values)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, r.prompt.tokenizer().CountTokens(value.String()), nil
+}
+
+// reduce reduces a variable until the prompt fits or the variable is empty,
+// and returns the last rendering.
+func (r budgetRenderer) reduce(v BudgetedVariable, tokens int) (llms.PromptValue, int, error) {
+	original := r.values[v.Name]
+	switch v.Strategy {
+	case TruncationDropHead, TruncationDropTail:
+		items := reflect.ValueOf(original)
+		if items.Kind() != reflect.Slice {
+			return nil, 0, fmt.Errorf("%w: %s: %s needs a slice, got %T", ErrInvalidBudgetedValue, v.Name, v.Strategy, original)
+		}
+		return r.shrink(v.Name, items.Len(), func(n int) any {
+			if v.Strategy == TruncationDropHead {
+				return items.Slice(items.Len()-n, items.Len()).Interface()
+			}
+			return items.Slice(0, n).Interface()
+		})
+	case TruncationTruncateHead, TruncationTruncateTail:
+		text, ok := original.(string)
+		if !ok {
+			return nil, 0, fmt.Errorf("%w: %s: %s needs a string, got %T", ErrInvalidBudgetedValue, v.Name, v.Strategy, original)
+		}
+		return r.shrinkText(v.Name, text, v.Strategy == TruncationTruncateHead)
+	case TruncationSummarize:
+		text, ok := original.(string)
+		if !ok || v.Summarize == nil {
+			return nil, 0, fmt.Errorf("%w: %s: %s needs a string and a Summarize function",
+				ErrInvalidBudgetedValue, v.Name, v.Strategy)
+		}
+		textTokens := r.prompt.tokenizer().CountTokens(text)
+		summary, err := v.Summarize(text, max(textTokens-(tokens-r.prompt.MaxTokens), 0))
+		if err != nil {
+			return nil, 0, fmt.Errorf("summarize %s: %w", v.Name, err)
+		}
+		r.values[v.Name] = summary
+		value, tokens, err := r.render()
+		if err != nil || tokens <= r.prompt.MaxTokens {
+			return value, tokens, err
+		}
+		return r.shrinkText(v.Name, summary, false)
+	}
+	return nil, 0, fmt.Errorf("%w: %s: unknown strategy %q", ErrInvalidBudgetedValue, v.Name, v.Strategy)
+}
+
+func (r budgetRenderer) shrinkText(name, text string, keepEnd bool) (llms.PromptValue, int, error) {
+	runes := []rune(text)
+	return r.shrink(name, len(runes), func(n int) any {
+		if keepEnd {
+			return strings.TrimLeftFunc(string(runes[len(runes)-n:]), isSpace)
+		}
+		return strings.TrimRightFunc(string(runes[:n]), isSpace)
+	})
+}
+
+// shrink finds, by bisection, the largest size up to total of the variable
+// for which the prompt fits, and leaves the variable at that size. If the
+// prompt does not fit even at size 0, the variable is left empty.
+func (r budgetRenderer) shrink(name string, total int, resize func(n int) any) (llms.PromptValue, int, error) {
+	var (
+		best       llms.PromptValue
+		bestTokens int
+		found      bool
+	)
+	lo, hi := 0, total-1
+	for lo <= hi {
+		mid := lo + (hi-lo)/2
+		r.values[name] = resize(mid)
+		value, tokens, err := r.render()
+		if err != nil {
+			return nil, 0, err
+		}
+		if tokens <= r.prompt.MaxTokens {
+			best, bestTokens, found = value, tokens, true
+			lo = mid + 1
+		} else {
+			hi = mid - 1
+		}
+	}
+	if found {
+		r.values[name] = resize(hi)
+		return best, bestTokens, nil
+	}
+	r.values[name] = resize(0)
+	return r.render()
+}
+
+func (p BudgetedPromptTemplate) tokenizer() Tokenizer { //nolint:ireturn
+	if p.Tokenizer == nil {
+		return ModelTokenizer("")
+	}
+	return p.Tokenizer
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\n' || r == '\t' || r == '\r'
+}
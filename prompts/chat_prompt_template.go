@@ -0,0 +1,101 @@
+package prompts
+
+import (
+	"context"
+	"slices"
+
+	"github.com/mateors/llmg/llms"
+)
+
+// ChatPromptTemplate is a prompt template for chat messages.
+type ChatPromptTemplate struct {
+	// Messages are the formatters of the messages, in order.
+	Messages []MessageFormatter
+
+	// PartialVariables represents a map of variable names to values or functions
+	// that return values. If the value is a function, it will be called when the
+	// prompt template is rendered.
+	PartialVariables map[string]any
+}
+
+var (
+	_ Formatter               = ChatPromptTemplate{}
+	_ ContextMessageFormatter = ChatPromptTemplate{}
+	_ ContextFormatPrompter   = ChatPromptTemplate{}
+)
+
+// NewChatPromptTemplate creates a new chat prompt template from a list of
+// message formatters.
+func NewChatPromptTemplate(messages []MessageFormatter) ChatPromptTemplate {
+	return ChatPromptTemplate{Messages: messages}
+}
+
+// Format formats the messages and returns them as a buffer string.
+func (p ChatPromptTemplate) Format(values map[string]any) (string, error) {
+	promptValue, err := p.FormatPrompt(values)
+	if err != nil {
+		return "", err
+	}
+	return promptValue.String(), nil
+}
+
+// FormatPrompt formats the messages into a chat prompt value.
+func (p ChatPromptTemplate) FormatPrompt(values map[string]any) (llms.PromptValue, error) { //nolint:ireturn
+	return p.FormatPromptContext(context.Background(), values)
+}
+
+// FormatPromptContext formats the messages into a chat prompt value, passing
+// ctx to the messages that use one, see ContextMessageFormatter.
+func (p ChatPromptTemplate) FormatPromptContext(ctx context.Context, values map[string]any) (llms.PromptValue, error) { //nolint:ireturn,lll
+	messages, err := p.FormatMessagesContext(ctx, values)
+	if err != nil {
+		return nil, err
+	}
+	return ChatPromptValue(messages), nil
+}
+
+// FormatMessages formats the messages with the values given.
+func (p ChatPromptTemplate) FormatMessages(values map[string]any) ([]llms.ChatMessage, error) {
+	return p.FormatMessagesContext(context.Background(), values)
+}
+
+// FormatMessagesContext formats the messages with the values given, passing
+// ctx to the messages that use one.
+func (p ChatPromptTemplate) FormatMessagesContext(ctx context.Context, values map[string]any) ([]llms.ChatMessage, error) { //nolint:lll
+	resolvedValues, err := resolvePartialValues(p.PartialVariables, values)
+	if err != nil {
+		return nil, err
+	}
+
+	formattedMessages := make([]llms.ChatMessage, 0, len(p.Messages))
+	for _, m := range p.Messages {
+		curFormattedMessages, err := FormatMessagesContext(ctx, m, resolvedValues)
+		if err != nil {
+			return nil, err
+		}
+		formattedMessages = append(formattedMessages, curFormattedMessages...)
+	}
+	return formattedMessages, nil
+}
+
+// Partial returns a copy of the chat prompt template with some of the
+// variables set, see PromptTemplate.Partial.
+func (p ChatPromptTemplate) Partial(values map[string]any) ChatPromptTemplate {
+	p.PartialVariables = mergePartialValues(p.PartialVariables, values)
+	return p
+}
+
+// GetInputVariables returns the input variables of all the messages, without
+// duplicates and without the partial variables.
+func (p ChatPromptTemplate) GetInputVariables() []string {
+	var inputVariables []string
+	for _, m := range p.Messages {
+		for _, v := range m.GetInputVariables() {
+			if _, ok := p.PartialVariables[v]; ok || slices.Contains(inputVariables, v) {
+				continue
+			}
+			inputVariables = append(inputVariables, v)
+		}
+	}
+	return inputVariables
+}
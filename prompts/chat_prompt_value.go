@@ -0,0 +1,22 @@
+package prompts
+
+import "github.com/mateors/llmg/llms"
+
+var _ llms.PromptValue = ChatPromptValue{}
+
+// ChatPromptValue is a prompt value that is a list of chat messages.
+type ChatPromptValue []llms.ChatMessage
+
+// String returns the chat messages as a buffer string.
+func (v ChatPromptValue) String() string {
+	s, err := llms.GetBufferString(v, "Human", "AI")
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+// Messages returns the chat messages.
+func (v ChatPromptValue) Messages() []llms.ChatMessage {
+	return v
+}
@@ -0,0 +1,12 @@
+package prompts
+
+import "context"
+
+// ExampleSelector selects the examples of a few-shot prompt for the input
+// values, see the exampleselector package.
+type ExampleSelector interface {
+	// AddExample adds an example to the ones to select from.
+	AddExample(ctx context.Context, example map[string]string) error
+	// SelectExamples returns the examples to use for the input values.
+	SelectExamples(ctx context.Context, inputVariables map[string]string) ([]map[string]string, error)
+}
@@ -0,0 +1,39 @@
+// Package exampleselector contains the example selectors of few-shot prompts,
+// see prompts.FewShotPromptTemplate.
+package exampleselector
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/mateors/llmg/prompts"
+)
+
+// Fixed is an example selector always selecting all of its examples.
+type Fixed struct {
+	mu       sync.RWMutex
+	examples []map[string]string
+}
+
+var _ prompts.ExampleSelector = (*Fixed)(nil)
+
+// NewFixed creates a selector of the given examples.
+func NewFixed(examples ...map[string]string) *Fixed {
+	return &Fixed{examples: examples}
+}
+
+// AddExample adds an example.
+func (s *Fixed) AddExample(_ context.Context, example map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.examples = append(s.examples, example)
+	return nil
+}
+
+// SelectExamples returns all the examples.
+func (s *Fixed) SelectExamples(context.Context, map[string]string) ([]map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.examples), nil
+}
@@ -0,0 +1,88 @@
+package exampleselector
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mateors/llmg/prompts"
+)
+
+// LengthBased is an example selector selecting examples, in order, as long as
+// the formatted examples and the input fit in a maximum length.
+type LengthBased struct {
+	mu             sync.RWMutex
+	examplePrompt  prompts.PromptTemplate
+	maxLength      int
+	lengthFunc     func(string) int
+	examples       []map[string]string
+	exampleLengths []int
+}
+
+var _ prompts.ExampleSelector = (*LengthBased)(nil)
+
+// NewLengthBased creates a selector of examples formatted with examplePrompt
+// that fit in maxLength, measured in tokens unless WithLengthFunction is
+// given. Counting tokens may download the tokenizer on first use, see
+// WithLengthFunction.
+func NewLengthBased(
+	examplePrompt prompts.PromptTemplate,
+	maxLength int,
+	examples []map[string]string,
+	opts ...Option,
+) (*LengthBased, error) {
+	o := applyOptions(opts...)
+	s := &LengthBased{
+		examplePrompt: examplePrompt,
+		maxLength:     maxLength,
+		lengthFunc:    o.lengthFunc,
+	}
+	for _, example := range examples {
+		if err := s.AddExample(context.Background(), example); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// AddExample adds an example. It fails if the example cannot be formatted.
+func (s *LengthBased) AddExample(_ context.Context, example map[string]string) error {
+	values := make(map[string]any, len(example))
+	for k, v := range example {
+		values[k] = v
+	}
+	formatted, err := s.examplePrompt.Format(values)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.examples = append(s.examples, example)
+	s.exampleLengths = append(s.exampleLengths, s.lengthFunc(formatted))
+	return nil
+}
+
+// SelectExamples returns the first examples that fit in the maximum length
+// left by the input values.
+func (s *LengthBased) SelectExamples(_ context.Context, inputVariables map[string]string) ([]map[string]string, error) {
+	inputs := make([]string, 0, len(inputVariables))
+	for _, k := range slices.Sorted(maps.Keys(inputVariables)) {
+		inputs = append(inputs, inputVariables[k])
+	}
+	remaining := s.maxLength - s.lengthFunc(strings.Join(inputs, " "))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var selected []map[string]string
+	for i, example := range s.examples {
+		remaining -= s.exampleLengths[i]
+		if remaining < 0 {
+			break
+		}
+		selected = append(selected, example)
+	}
+	return selected, nil
+}
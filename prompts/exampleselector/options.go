@@ -0,0 +1,76 @@
+package exampleselector
+
+import (
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/vectorstores"
+)
+
+const (
+	_defaultFetchK = 20
+	_defaultLambda = 0.5
+)
+
+type options struct {
+	lengthFunc   func(string) int
+	inputKeys    []string
+	storeOptions []vectorstores.Option
+	fetchK       int
+	lambda       float64
+}
+
+// Option is a function that configures an example selector.
+type Option func(*options)
+
+func applyOptions(opts ...Option) options {
+	o := options{
+		lengthFunc: func(text string) int { return llms.CountTokens("", text) },
+		fetchK:     _defaultFetchK,
+		lambda:     _defaultLambda,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithLengthFunction sets how the length of a text is measured by the
+// length-based selector. Defaults to the number of tokens, see
+// llms.CountTokens, whose tiktoken encoding is downloaded the first time it
+// is used unless it is cached: set a length function to avoid the network.
+func WithLengthFunction(fn func(text string) int) Option {
+	return func(o *options) {
+		o.lengthFunc = fn
+	}
+}
+
+// WithInputKeys sets the keys of the examples and input values that are
+// embedded by the semantic selectors. Defaults to all keys.
+func WithInputKeys(keys ...string) Option {
+	return func(o *options) {
+		o.inputKeys = keys
+	}
+}
+
+// WithVectorStoreOptions sets the options passed to the vector store by the
+// semantic selectors.
+func WithVectorStoreOptions(opts ...vectorstores.Option) Option {
+	return func(o *options) {
+		o.storeOptions = append(o.storeOptions, opts...)
+	}
+}
+
+// WithFetchK sets how many similar examples the maximal marginal relevance
+// selector fetches to choose from. Defaults to 20.
+func WithFetchK(fetchK int) Option {
+	return func(o *options) {
+		o.fetchK = fetchK
+	}
+}
+
+// WithLambda sets the trade-off between similarity to the input, at 1, and
+// diversity, at 0, of the maximal marginal relevance selector. Defaults to 0.5.
+func WithLambda(lambda float64) Option {
+	return func(o *options) {
+		o.lambda = lambda
+	}
+}
@@ -0,0 +1,218 @@
+package exampleselector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/mateors/llmg/embeddings"
+	"github.com/mateors/llmg/prompts"
+	"github.com/mateors/llmg/schema"
+	"github.com/mateors/llmg/vectorstores"
+)
+
+// ErrEmbedderWrongNumberVectors is returned when the embedder does not return
+// one vector per example.
+var ErrEmbedderWrongNumberVectors = errors.New("number of vectors from embedder does not match number of examples")
+
+// SemanticSimilarity is an example selector selecting the examples most
+// similar to the input. The examples are stored in a vector store, with the
+// example as the metadata of the document.
+type SemanticSimilarity struct {
+	store        vectorstores.VectorStore
+	k            int
+	inputKeys    []string
+	storeOptions []vectorstores.Option
+}
+
+var _ prompts.ExampleSelector = (*SemanticSimilarity)(nil)
+
+// NewSemanticSimilarity creates a selector of the k examples most similar to
+// the input, stored in store.
+func NewSemanticSimilarity(store vectorstores.VectorStore, k int, opts ...Option) *SemanticSimilarity {
+	o := applyOptions(opts...)
+	return &SemanticSimilarity{
+		store:        store,
+		k:            k,
+		inputKeys:    o.inputKeys,
+		storeOptions: o.storeOptions,
+	}
+}
+
+// AddExample adds an example to the vector store.
+func (s *SemanticSimilarity) AddExample(ctx context.Context, example map[string]string) error {
+	metadata := make(map[string]any, len(example))
+	for k, v := range example {
+		metadata[k] = v
+	}
+	doc := schema.Document{PageContent: joinValues(example, s.inputKeys), Metadata: metadata}
+	_, err := s.store.AddDocuments(ctx, []schema.Document{doc}, s.storeOptions...)
+	return err
+}
+
+// SelectExamples returns the examples most similar to the input values.
+func (s *SemanticSimilarity) SelectExamples(
+	ctx context.Context,
+	inputVariables map[string]string,
+) ([]map[string]string, error) {
+	docs, err := s.store.SimilaritySearch(ctx, joinValues(inputVariables, s.inputKeys), s.k, s.storeOptions...)
+	if err != nil {
+		return nil, err
+	}
+	return documentExamples(docs), nil
+}
+
+// MaxMarginalRelevance is an example selector selecting examples similar to
+// the input but diverse, with maximal marginal relevance: among the examples
+// most similar to the input, each selected example is the one maximizing
+// lambda * similarity to the input - (1 - lambda) * similarity to the examples
+// already selected.
+type MaxMarginalRelevance struct {
+	*SemanticSimilarity
+	embedder embeddings.Embedder
+	fetchK   int
+	lambda   float64
+}
+
+var _ prompts.ExampleSelector = (*MaxMarginalRelevance)(nil)
+
+// NewMaxMarginalRelevance creates a selector of k examples stored in store.
+// The candidates fetched from the store and the input are compared with the
+// vectors of embedder.
+func NewMaxMarginalRelevance(
+	store vectorstores.VectorStore,
+	embedder embeddings.Embedder,
+	k int,
+	opts ...Option,
+) *MaxMarginalRelevance {
+	o := applyOptions(opts...)
+	return &MaxMarginalRelevance{
+		SemanticSimilarity: NewSemanticSimilarity(store, k, opts...),
+		embedder:           embedder,
+		fetchK:             max(o.fetchK, k),
+		lambda:             o.lambda,
+	}
+}
+
+// SelectExamples returns diverse examples similar to the input values.
+func (s *MaxMarginalRelevance) SelectExamples(
+	ctx context.Context,
+	inputVariables map[string]string,
+) ([]map[string]string, error) {
+	query := joinValues(inputVariables, s.inputKeys)
+	docs, err := s.store.SimilaritySearch(ctx, query, s.fetchK, s.storeOptions...)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	queryVector, err := s.embedder.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.PageContent
+	}
+	vectors, err := s.embedder.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(docs) {
+		return nil, ErrEmbedderWrongNumberVectors
+	}
+
+	selected := maximalMarginalRelevance(queryVector, vectors, s.lambda, s.k)
+	result := make([]schema.Document, 0, len(selected))
+	for _, i := range selected {
+		result = append(result, docs[i])
+	}
+	return documentExamples(result), nil
+}
+
+// maximalMarginalRelevance returns the indexes of the k vectors selected by
+// maximal marginal relevance, in order of selection.
+func maximalMarginalRelevance(query []float32, vectors [][]float32, lambda float64, k int) []int {
+	relevance := make([]float64, len(vectors))
+	for i, v := range vectors {
+		relevance[i] = cosineSimilarity(query, v)
+	}
+
+	var selected []int
+	for len(selected) < min(k, len(vectors)) {
+		best, bestScore := -1, math.Inf(-1)
+		for i, v := range vectors {
+			if slices.Contains(selected, i) {
+				continue
+			}
+			redundancy := 0.0
+			if len(selected) > 0 {
+				redundancy = math.Inf(-1)
+				for _, j := range selected {
+					redundancy = max(redundancy, cosineSimilarity(v, vectors[j]))
+				}
+			}
+			score := lambda*relevance[i] - (1-lambda)*redundancy
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		// Only NaN scores are left, e.g. from zero or mismatched vectors.
+		if best < 0 {
+			break
+		}
+		selected = append(selected, best)
+	}
+	return selected
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range min(len(a), len(b)) {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// joinValues joins the values of keys, or of all keys in order if keys is
+// empty, into the text that is embedded.
+func joinValues(values map[string]string, keys []string) string {
+	if len(keys) == 0 {
+		keys = slices.Sorted(maps.Keys(values))
+	}
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if v, ok := values[k]; ok {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// documentExamples returns the examples stored in the metadata of documents.
+func documentExamples(docs []schema.Document) []map[string]string {
+	examples := make([]map[string]string, 0, len(docs))
+	for _, doc := range docs {
+		example := make(map[string]string, len(doc.Metadata))
+		for k, v := range doc.Metadata {
+			if s, ok := v.(string); ok {
+				example[k] = s
+			} else {
+				example[k] = fmt.Sprint(v)
+			}
+		}
+		examples = append(examples, example)
+	}
+	return examples
+}
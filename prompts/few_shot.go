@@ -0,0 +1,265 @@
+package prompts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mateors/llmg/llms"
+)
+
+const _defaultExampleSeparator = "\n\n"
+
+var (
+	// ErrNoExamples is returned when neither examples nor an example selector
+	// are given to a few-shot prompt.
+	ErrNoExamples = errors.New("examples or an example selector must be provided")
+	// ErrExamplesAndSelector is returned when both examples and an example
+	// selector are given to a few-shot prompt.
+	ErrExamplesAndSelector = errors.New("only one of examples and example selector can be provided")
+)
+
+// FewShotPromptTemplate is a prompt template made of a prefix, examples
+// formatted with ExamplePrompt, and a suffix. The examples are either fixed
+// or chosen for the input values by an ExampleSelector.
+type FewShotPromptTemplate struct {
+	// Examples are the examples to format, if no ExampleSelector is set.
+	Examples []map[string]string
+	// ExampleSelector selects the examples to format, if no Examples are set.
+	ExampleSelector ExampleSelector
+	// ExamplePrompt formats a single example.
+	ExamplePrompt PromptTemplate
+	// Prefix is the template placed before the examples.
+	Prefix string
+	// Suffix is the template placed after the examples, usually holding the
+	// input.
+	Suffix string
+	// InputVariables are the variables the prefix and suffix expect.
+	InputVariables []string
+	// PartialVariables represents a map of variable names to values or functions
+	// that return values. If the value is a function, it will be called when the
+	// prompt template is rendered.
+	PartialVariables map[string]any
+	// ExampleSeparator is the string placed between the prefix, the examples
+	// and the suffix. Defaults to a blank line.
+	ExampleSeparator string
+	// TemplateFormat is the format of the prefix and suffix.
+	TemplateFormat TemplateFormat
+}
+
+var (
+	_ Formatter             = FewShotPromptTemplate{}
+	_ ContextFormatPrompter = FewShotPromptTemplate{}
+)
+
+// NewFewShotPromptTemplate creates a few-shot prompt template. Exactly one of
+// examples and exampleSelector must be set.
+func NewFewShotPromptTemplate(
+	examplePrompt PromptTemplate,
+	examples []map[string]string,
+	exampleSelector ExampleSelector,
+	prefix string,
+	suffix string,
+	inputVariables []string,
+) (FewShotPromptTemplate, error) {
+	if err := checkExamples(examples, exampleSelector); err != nil {
+		return FewShotPromptTemplate{}, err
+	}
+	return FewShotPromptTemplate{
+		Examples:         examples,
+		ExampleSelector:  exampleSelector,
+		ExamplePrompt:    examplePrompt,
+		Prefix:           prefix,
+		Suffix:           suffix,
+		InputVariables:   inputVariables,
+		ExampleSeparator: _defaultExampleSeparator,
+		TemplateFormat:   TemplateFormatGoTemplate,
+	}, nil
+}
+
+// Format formats the prompt with the examples selected for the values. The
+// example selector is called with a background context, see FormatContext.
+func (p FewShotPromptTemplate) Format(values map[string]any) (string, error) {
+	return p.FormatContext(context.Background(), values)
+}
+
+// FormatContext formats the prompt with the examples selected for the values,
+// calling the example selector with ctx.
+func (p FewShotPromptTemplate) FormatContext(ctx context.Context, values map[string]any) (string, error) {
+	resolvedValues, err := resolvePartialValues(p.PartialVariables, values)
+	if err != nil {
+		return "", err
+	}
+
+	examples, err := getExamples(ctx, p.Examples, p.ExampleSelector, resolvedValues)
+	if err != nil {
+		return "", err
+	}
+
+	pieces := make([]string, 0, len(examples)+2)
+	prefix, err := RenderTemplate(p.Prefix, p.TemplateFormat, resolvedValues)
+	if err != nil {
+		return "", err
+	}
+	pieces = append(pieces, prefix)
+	for _, example := range examples {
+		formatted, err := p.ExamplePrompt.Format(exampleValues(example))
+		if err != nil {
+			return "", fmt.Errorf("format example: %w", err)
+		}
+		pieces = append(pieces, formatted)
+	}
+	suffix, err := RenderTemplate(p.Suffix, p.TemplateFormat, resolvedValues)
+	if err != nil {
+		return "", err
+	}
+	pieces = append(pieces, suffix)
+
+	separator := p.ExampleSeparator
+	if separator == "" {
+		separator = _defaultExampleSeparator
+	}
+	return strings.Join(nonEmpty(pieces), separator), nil
+}
+
+// FormatPrompt formats the prompt and returns a string prompt value.
+func (p FewShotPromptTemplate) FormatPrompt(values map[string]any) (llms.PromptValue, error) { //nolint:ireturn
+	return p.FormatPromptContext(context.Background(), values)
+}
+
+// FormatPromptContext formats the prompt with ctx, see FormatContext.
+func (p FewShotPromptTemplate) FormatPromptContext(ctx context.Context, values map[string]any) (llms.PromptValue, error) { //nolint:ireturn,lll
+	f, err := p.FormatContext(ctx, values)
+	if err != nil {
+		return nil, err
+	}
+	return StringPromptValue(f), nil
+}
+
+// GetInputVariables returns the input variables the prompt expects.
+func (p FewShotPromptTemplate) GetInputVariables() []string {
+	return p.InputVariables
+}
+
+// FewShotChatMessagePromptTemplate is a message formatter inserting examples
+// as chat messages, usually a human message and an AI message each. It is
+// meant to be one of the messages of a ChatPromptTemplate.
+type FewShotChatMessagePromptTemplate struct {
+	// Examples are the examples to format, if no ExampleSelector is set.
+	Examples []map[string]string
+	// ExampleSelector selects the examples to format, if no Examples are set.
+	ExampleSelector ExampleSelector
+	// ExamplePrompt formats a single example into messages.
+	ExamplePrompt MessageFormatter
+	// InputVariables are the variables passed to the example selector.
+	InputVariables []string
+}
+
+var _ ContextMessageFormatter = FewShotChatMessagePromptTemplate{}
+
+// NewFewShotChatMessagePromptTemplate creates a few-shot chat message prompt
+// template. Exactly one of examples and exampleSelector must be set.
+func NewFewShotChatMessagePromptTemplate(
+	examplePrompt MessageFormatter,
+	examples []map[string]string,
+	exampleSelector ExampleSelector,
+	inputVariables []string,
+) (FewShotChatMessagePromptTemplate, error) {
+	if err := checkExamples(examples, exampleSelector); err != nil {
+		return FewShotChatMessagePromptTemplate{}, err
+	}
+	return FewShotChatMessagePromptTemplate{
+		Examples:        examples,
+		ExampleSelector: exampleSelector,
+		ExamplePrompt:   examplePrompt,
+		InputVariables:  inputVariables,
+	}, nil
+}
+
+// FormatMessages returns the messages of the examples selected for the
+// values. The example selector is called with a background context, see
+// FormatMessagesContext.
+func (p FewShotChatMessagePromptTemplate) FormatMessages(values map[string]any) ([]llms.ChatMessage, error) {
+	return p.FormatMessagesContext(context.Background(), values)
+}
+
+// FormatMessagesContext returns the messages of the examples selected for the
+// values, calling the example selector with ctx.
+func (p FewShotChatMessagePromptTemplate) FormatMessagesContext(
+	ctx context.Context,
+	values map[string]any,
+) ([]llms.ChatMessage, error) {
+	examples, err := getExamples(ctx, p.Examples, p.ExampleSelector, values)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []llms.ChatMessage
+	for _, example := range examples {
+		exampleMessages, err := p.ExamplePrompt.FormatMessages(exampleValues(example))
+		if err != nil {
+			return nil, fmt.Errorf("format example: %w", err)
+		}
+		messages = append(messages, exampleMessages...)
+	}
+	return messages, nil
+}
+
+// GetInputVariables returns the input variables the prompt expects.
+func (p FewShotChatMessagePromptTemplate) GetInputVariables() []string {
+	return p.InputVariables
+}
+
+func checkExamples(examples []map[string]string, exampleSelector ExampleSelector) error {
+	switch {
+	case examples != nil && exampleSelector != nil:
+		return ErrExamplesAndSelector
+	case examples == nil && exampleSelector == nil:
+		return ErrNoExamples
+	}
+	return nil
+}
+
+func getExamples(
+	ctx context.Context,
+	examples []map[string]string,
+	exampleSelector ExampleSelector,
+	values map[string]any,
+) ([]map[string]string, error) {
+	if exampleSelector == nil {
+		return examples, nil
+	}
+
+	inputs := make(map[string]string, len(values))
+	for k, v := range values {
+		if s, ok := v.(string); ok {
+			inputs[k] = s
+		} else {
+			inputs[k] = fmt.Sprint(v)
+		}
+	}
+	selected, err := exampleSelector.SelectExamples(ctx, inputs)
+	if err != nil {
+		return nil, fmt.Errorf("select examples: %w", err)
+	}
+	return selected, nil
+}
+
+func exampleValues(example map[string]string) map[string]any {
+	values := make(map[string]any, len(example))
+	for k, v := range example {
+		values[k] = v
+	}
+	return values
+}
+
+func nonEmpty(pieces []string) []string {
+	result := pieces[:0]
+	for _, piece := range pieces {
+		if piece != "" {
+			result = append(result, piece)
+		}
+	}
+	return result
+}
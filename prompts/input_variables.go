@@ -0,0 +1,306 @@
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"text/template/parse"
+
+	"github.com/nikolalohinski/gonja"
+	"github.com/nikolalohinski/gonja/nodes"
+
+	"github.com/mateors/llmg/prompts/internal/fstring"
+)
+
+var (
+	// ErrMissingInputVariables is returned when a template uses variables that
+	// are neither input variables nor partial variables.
+	ErrMissingInputVariables = errors.New("template uses undeclared variables")
+	// ErrUnusedInputVariables is returned when input variables are not used by
+	// the template.
+	ErrUnusedInputVariables = errors.New("input variables not used by the template")
+)
+
+// InferInputVariables returns the variables a template uses, in order of first
+// appearance. Only the top-level names are returned: a template using
+// {{.user.name}} expects a "user" variable.
+func InferInputVariables(template string, templateFormat TemplateFormat) ([]string, error) {
+	switch templateFormat {
+	case TemplateFormatGoTemplate:
+		t, err := compile(TemplateFormatGoTemplate, template, parseGoTemplate)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		if t.Tree != nil {
+			names = goTemplateVariables(names, t.Tree.Root, true)
+		}
+		return names, nil
+	case TemplateFormatJinja2:
+		t, err := compile(TemplateFormatJinja2, template, gonja.FromString)
+		if err != nil {
+			return nil, err
+		}
+		return jinja2Variables(t.Root), nil
+	case TemplateFormatFString:
+		return fstring.Variables(template)
+	}
+	return nil, newInvalidTemplateError(templateFormat)
+}
+
+// NewPromptTemplateWithFormat returns a new prompt template whose input
+// variables are inferred from the template.
+func NewPromptTemplateWithFormat(template string, templateFormat TemplateFormat) (PromptTemplate, error) {
+	inputVars, err := InferInputVariables(template, templateFormat)
+	if err != nil {
+		return PromptTemplate{}, err
+	}
+	return PromptTemplate{
+		Template:       template,
+		InputVariables: inputVars,
+		TemplateFormat: templateFormat,
+	}, nil
+}
+
+// NewValidatedPromptTemplate returns a new prompt template after checking
+// that the template uses exactly the input variables given, see Validate.
+func NewValidatedPromptTemplate(
+	template string,
+	inputVars []string,
+	templateFormat TemplateFormat,
+) (PromptTemplate, error) {
+	p := PromptTemplate{
+		Template:       template,
+		InputVariables: inputVars,
+		TemplateFormat: templateFormat,
+	}
+	if err := p.Validate(); err != nil {
+		return PromptTemplate{}, err
+	}
+	return p, nil
+}
+
+// Validate checks that every variable the template uses is an input variable
+// or a partial variable, and that every input variable is used.
+func (p PromptTemplate) Validate() error {
+	used, err := InferInputVariables(p.Template, p.TemplateFormat)
+	if err != nil {
+		return err
+	}
+
+	var missing, unused []string
+	for _, v := range used {
+		_, partial := p.PartialVariables[v]
+		if !partial && !slices.Contains(p.InputVariables, v) {
+			missing = append(missing, v)
+		}
+	}
+	for _, v := range p.InputVariables {
+		if !slices.Contains(used, v) {
+			unused = append(unused, v)
+		}
+	}
+
+	var errs []error
+	if len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("%w: %v", ErrMissingInputVariables, missing))
+	}
+	if len(unused) > 0 {
+		errs = append(errs, fmt.Errorf("%w: %v", ErrUnusedInputVariables, unused))
+	}
+	return errors.Join(errs...)
+}
+
+// goTemplateVariables appends the top-level fields used under node. Inside
+// range and with blocks the dot is not the top-level data anymore, only $.x
+// refers to it there.
+func goTemplateVariables(names []string, node parse.Node, topLevel bool) []string { //nolint:cyclop
+	add := func(name string) {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return names
+		}
+		for _, child := range n.Nodes {
+			names = goTemplateVariables(names, child, topLevel)
+		}
+	case *parse.ActionNode:
+		names = goTemplateVariables(names, n.Pipe, topLevel)
+	case *parse.PipeNode:
+		if n == nil {
+			return names
+		}
+		for _, cmd := range n.Cmds {
+			names = goTemplateVariables(names, cmd, topLevel)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			names = goTemplateVariables(names, arg, topLevel)
+		}
+	case *parse.ChainNode:
+		names = goTemplateVariables(names, n.Node, topLevel)
+	case *parse.FieldNode:
+		if topLevel {
+			add(n.Ident[0])
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			add(n.Ident[1])
+		}
+	case *parse.IfNode:
+		names = goTemplateVariables(names, n.Pipe, topLevel)
+		names = goTemplateVariables(names, n.List, topLevel)
+		names = goTemplateVariables(names, n.ElseList, topLevel)
+	case *parse.RangeNode:
+		names = goTemplateVariables(names, n.Pipe, topLevel)
+		names = goTemplateVariables(names, n.List, false)
+		names = goTemplateVariables(names, n.ElseList, topLevel)
+	case *parse.WithNode:
+		names = goTemplateVariables(names, n.Pipe, topLevel)
+		names = goTemplateVariables(names, n.List, false)
+		names = goTemplateVariables(names, n.ElseList, topLevel)
+	case *parse.TemplateNode:
+		names = goTemplateVariables(names, n.Pipe, topLevel)
+	}
+	return names
+}
+
+// jinja2Globals are the names defined by gonja itself.
+var jinja2Globals = []string{ //nolint:gochecknoglobals
+	"cycler", "dict", "joiner", "lipsum", "namespace", "range",
+	"loop", "caller", "varargs", "kwargs", "self",
+}
+
+// jinja2Variables returns the names a gonja template reads and does not
+// define itself with for, set, with or macro statements. The statements keep
+// their fields unexported, so the tree is walked with reflection.
+func jinja2Variables(root *nodes.Template) []string {
+	w := &jinja2Walker{seen: make(map[uintptr]bool)}
+	w.walk(reflect.ValueOf(root), false)
+
+	var names []string
+	for _, name := range w.used {
+		if !slices.Contains(w.bound, name) && !slices.Contains(jinja2Globals, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+type jinja2Walker struct {
+	seen  map[uintptr]bool
+	used  []string
+	bound []string
+}
+
+var (
+	_jinja2NameType     = reflect.TypeOf(nodes.Name{})     //nolint:gochecknoglobals
+	_jinja2VariableType = reflect.TypeOf(nodes.Variable{}) //nolint:gochecknoglobals
+	_jinja2MacroType    = reflect.TypeOf(nodes.Macro{})    //nolint:gochecknoglobals
+	_jinja2StmtPkgPath  = "github.com/nikolalohinski/gonja/builtins/statements"
+)
+
+// walk records the names found under v, as bound names if binding is set.
+func (w *jinja2Walker) walk(v reflect.Value, binding bool) { //nolint:cyclop
+	switch v.Kind() { //nolint:exhaustive
+	case reflect.Pointer:
+		if v.IsNil() || w.seen[v.Pointer()] {
+			return
+		}
+		w.seen[v.Pointer()] = true
+		w.walk(v.Elem(), binding)
+	case reflect.Interface:
+		if !v.IsNil() {
+			w.walk(v.Elem(), binding)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			w.walk(v.Index(i), binding)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			w.walk(iter.Value(), binding)
+		}
+	case reflect.Struct:
+		w.walkStruct(v, binding)
+	}
+}
+
+func (w *jinja2Walker) walkStruct(v reflect.Value, binding bool) {
+	t := v.Type()
+	switch {
+	case t == _jinja2NameType:
+		if token := v.Field(0); !token.IsNil() {
+			w.record(token.Elem().FieldByName("Val").String(), binding)
+		}
+		return
+	case t == _jinja2VariableType:
+		parts := v.FieldByName("Parts")
+		if parts.Len() > 0 {
+			part := parts.Index(0).Elem()
+			if part.FieldByName("Type").Int() == nodes.VarTypeIdent {
+				w.record(part.FieldByName("S").String(), binding)
+			}
+		}
+		for i := range parts.Len() {
+			part := parts.Index(i).Elem()
+			w.walk(part.FieldByName("Args"), false)
+			w.walk(part.FieldByName("Kwargs"), false)
+		}
+		return
+	case t == _jinja2MacroType:
+		w.record(v.FieldByName("Name").String(), true)
+		for _, kwarg := range iterSlice(v.FieldByName("Kwargs")) {
+			w.walk(kwarg.Elem().FieldByName("Key"), true)
+			w.walk(kwarg.Elem().FieldByName("Value"), false)
+		}
+		w.walk(v.FieldByName("Wrapper"), false)
+		return
+	case t.PkgPath() == _jinja2StmtPkgPath:
+		switch t.Name() {
+		case "ForStmt":
+			w.record(v.FieldByName("key").String(), true)
+			w.record(v.FieldByName("value").String(), true)
+		case "SetStmt":
+			w.walk(v.FieldByName("Target"), true)
+			w.walk(v.FieldByName("Expression"), false)
+			return
+		case "WithStmt":
+			for _, key := range v.FieldByName("Pairs").MapKeys() {
+				w.record(key.String(), true)
+			}
+		}
+	}
+
+	for i := range v.NumField() {
+		w.walk(v.Field(i), binding)
+	}
+}
+
+func (w *jinja2Walker) record(name string, binding bool) {
+	if name == "" {
+		return
+	}
+	list := &w.used
+	if binding {
+		list = &w.bound
+	}
+	if !slices.Contains(*list, name) {
+		*list = append(*list, name)
+	}
+}
+
+func iterSlice(v reflect.Value) []reflect.Value {
+	values := make([]reflect.Value, v.Len())
+	for i := range values {
+		values[i] = v.Index(i)
+	}
+	return values
+}
@@ -18,3 +18,14 @@ func Format(template string, values map[string]any) (string, error) {
 	}
 	return string(p.result), nil
 }
+
+// Variables returns the names of the variables of the given template, in
+// order of first appearance.
+func Variables(template string) ([]string, error) {
+	p := newParser(template, nil)
+	p.collect = true
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.names, nil
+}
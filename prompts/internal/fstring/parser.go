@@ -2,6 +2,7 @@ package fstring
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -11,6 +12,10 @@ type parser struct {
 	result []rune
 	idx    int
 	values map[string]any
+
+	// collect makes parse record the variable names instead of formatting.
+	collect bool
+	names   []string
 }
 
 func newParser(s string, values map[string]any) *parser {
@@ -41,6 +46,12 @@ func (r *parser) parse() error {
 		if valName == "" {
 			return ErrEmptyExpression
 		}
+		if r.collect {
+			if !slices.Contains(r.names, valName) {
+				r.names = append(r.names, valName)
+			}
+			continue
+		}
 		val, ok := r.values[valName]
 		if !ok {
 			return fmt.Errorf("%w: %s", ErrArgsNotDefined, valName)
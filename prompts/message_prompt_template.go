@@ -0,0 +1,141 @@
+package prompts
+
+import (
+	"fmt"
+
+	"github.com/mateors/llmg/llms"
+)
+
+// SystemMessagePromptTemplate is a message formatter that returns a system message.
+type SystemMessagePromptTemplate struct {
+	Prompt PromptTemplate
+}
+
+var _ MessageFormatter = SystemMessagePromptTemplate{}
+
+// NewSystemMessagePromptTemplate creates a new system message prompt template.
+func NewSystemMessagePromptTemplate(template string, inputVariables []string) SystemMessagePromptTemplate {
+	return SystemMessagePromptTemplate{Prompt: NewPromptTemplate(template, inputVariables)}
+}
+
+// FormatMessages formats the message with the values given.
+func (p SystemMessagePromptTemplate) FormatMessages(values map[string]any) ([]llms.ChatMessage, error) {
+	text, err := p.Prompt.Format(values)
+	return []llms.ChatMessage{llms.SystemChatMessage{Content: text}}, err
+}
+
+// GetInputVariables returns the input variables the prompt expects.
+func (p SystemMessagePromptTemplate) GetInputVariables() []string {
+	return p.Prompt.InputVariables
+}
+
+// AIMessagePromptTemplate is a message formatter that returns an AI message.
+type AIMessagePromptTemplate struct {
+	Prompt PromptTemplate
+}
+
+var _ MessageFormatter = AIMessagePromptTemplate{}
+
+// NewAIMessagePromptTemplate creates a new AI message prompt template.
+func NewAIMessagePromptTemplate(template string, inputVariables []string) AIMessagePromptTemplate {
+	return AIMessagePromptTemplate{Prompt: NewPromptTemplate(template, inputVariables)}
+}
+
+// FormatMessages formats the message with the values given.
+func (p AIMessagePromptTemplate) FormatMessages(values map[string]any) ([]llms.ChatMessage, error) {
+	text, err := p.Prompt.Format(values)
+	return []llms.ChatMessage{llms.AIChatMessage{Content: text}}, err
+}
+
+// GetInputVariables returns the input variables the prompt expects.
+func (p AIMessagePromptTemplate) GetInputVariables() []string {
+	return p.Prompt.InputVariables
+}
+
+// HumanMessagePromptTemplate is a message formatter that returns a human message.
+type HumanMessagePromptTemplate struct {
+	Prompt PromptTemplate
+}
+
+var _ MessageFormatter = HumanMessagePromptTemplate{}
+
+// NewHumanMessagePromptTemplate creates a new human message prompt template.
+func NewHumanMessagePromptTemplate(template string, inputVariables []string) HumanMessagePromptTemplate {
+	return HumanMessagePromptTemplate{Prompt: NewPromptTemplate(template, inputVariables)}
+}
+
+// FormatMessages formats the message with the values given.
+func (p HumanMessagePromptTemplate) FormatMessages(values map[string]any) ([]llms.ChatMessage, error) {
+	text, err := p.Prompt.Format(values)
+	return []llms.ChatMessage{llms.HumanChatMessage{Content: text}}, err
+}
+
+// GetInputVariables returns the input variables the prompt expects.
+func (p HumanMessagePromptTemplate) GetInputVariables() []string {
+	return p.Prompt.InputVariables
+}
+
+// GenericMessagePromptTemplate is a message formatter that returns a message
+// with a custom role.
+type GenericMessagePromptTemplate struct {
+	Prompt PromptTemplate
+	Role   string
+}
+
+var _ MessageFormatter = GenericMessagePromptTemplate{}
+
+// NewGenericMessagePromptTemplate creates a new generic message prompt template.
+func NewGenericMessagePromptTemplate(role, template string, inputVariables []string) GenericMessagePromptTemplate {
+	return GenericMessagePromptTemplate{
+		Prompt: NewPromptTemplate(template, inputVariables),
+		Role:   role,
+	}
+}
+
+// FormatMessages formats the message with the values given.
+func (p GenericMessagePromptTemplate) FormatMessages(values map[string]any) ([]llms.ChatMessage, error) {
+	text, err := p.Prompt.Format(values)
+	return []llms.ChatMessage{llms.GenericChatMessage{Content: text, Role: p.Role}}, err
+}
+
+// GetInputVariables returns the input variables the prompt expects.
+func (p GenericMessagePromptTemplate) GetInputVariables() []string {
+	return p.Prompt.InputVariables
+}
+
+// MessagesPlaceholder is a message formatter that inserts the list of chat
+// messages found in a variable, e.g. the history of a memory created with
+// ReturnMessages.
+type MessagesPlaceholder struct {
+	VariableName string
+	// Optional makes a missing variable insert no message instead of failing.
+	Optional bool
+}
+
+var _ MessageFormatter = MessagesPlaceholder{}
+
+// FormatMessages returns the messages of the variable. The value must be a
+// []llms.ChatMessage.
+func (p MessagesPlaceholder) FormatMessages(values map[string]any) ([]llms.ChatMessage, error) {
+	value, ok := values[p.VariableName]
+	if !ok || value == nil {
+		if p.Optional {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrMissingPlaceholderVariable, p.VariableName)
+	}
+	messages, ok := value.([]llms.ChatMessage)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is %T", ErrNeedChatMessageList, p.VariableName, value)
+	}
+	return messages, nil
+}
+
+// GetInputVariables returns the variable of the placeholder, or nothing if it
+// is optional.
+func (p MessagesPlaceholder) GetInputVariables() []string {
+	if p.Optional {
+		return nil
+	}
+	return []string{p.VariableName}
+}
@@ -0,0 +1,92 @@
+package prompts
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/mateors/llmg/llms"
+)
+
+// PipelinePrompt is a named step of a PipelinePromptTemplate.
+type PipelinePrompt struct {
+	// Name is the variable the rendered prompt is stored in.
+	Name string
+	// Prompt is the prompt to render.
+	Prompt FormatPrompter
+}
+
+// PipelinePromptTemplate is a prompt template composed of sub-templates. Each
+// sub-template is rendered in order and its result is passed, as the variable
+// of its name, to the following sub-templates and to the final prompt.
+type PipelinePromptTemplate struct {
+	// FinalPrompt is the prompt rendered last, with the input values and the
+	// results of the pipeline prompts.
+	FinalPrompt FormatPrompter
+	// PipelinePrompts are the sub-templates, rendered in order.
+	PipelinePrompts []PipelinePrompt
+}
+
+var (
+	_ Formatter             = PipelinePromptTemplate{}
+	_ ContextFormatPrompter = PipelinePromptTemplate{}
+)
+
+// NewPipelinePromptTemplate creates a pipeline prompt template.
+func NewPipelinePromptTemplate(finalPrompt FormatPrompter, pipelinePrompts []PipelinePrompt) PipelinePromptTemplate {
+	return PipelinePromptTemplate{
+		FinalPrompt:     finalPrompt,
+		PipelinePrompts: pipelinePrompts,
+	}
+}
+
+// Format renders the pipeline and returns the final prompt as a string.
+func (p PipelinePromptTemplate) Format(values map[string]any) (string, error) {
+	value, err := p.FormatPrompt(values)
+	if err != nil {
+		return "", err
+	}
+	return value.String(), nil
+}
+
+// FormatPrompt renders the pipeline prompts, then the final prompt.
+func (p PipelinePromptTemplate) FormatPrompt(values map[string]any) (llms.PromptValue, error) { //nolint:ireturn
+	return p.FormatPromptContext(context.Background(), values)
+}
+
+// FormatPromptContext renders the pipeline prompts, then the final prompt,
+// passing ctx to the prompts that use one.
+func (p PipelinePromptTemplate) FormatPromptContext(ctx context.Context, values map[string]any) (llms.PromptValue, error) { //nolint:ireturn,lll
+	allValues := make(map[string]any, len(values)+len(p.PipelinePrompts))
+	for k, v := range values {
+		allValues[k] = v
+	}
+
+	for _, pp := range p.PipelinePrompts {
+		value, err := FormatPromptContext(ctx, pp.Prompt, allValues)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline prompt %s: %w", pp.Name, err)
+		}
+		allValues[pp.Name] = value.String()
+	}
+	return FormatPromptContext(ctx, p.FinalPrompt, allValues)
+}
+
+// GetInputVariables returns the input variables of the pipeline prompts and
+// of the final prompt, without the variables produced by the pipeline.
+func (p PipelinePromptTemplate) GetInputVariables() []string {
+	var inputVariables, produced []string
+	add := func(variables []string) {
+		for _, v := range variables {
+			if !slices.Contains(produced, v) && !slices.Contains(inputVariables, v) {
+				inputVariables = append(inputVariables, v)
+			}
+		}
+	}
+	for _, pp := range p.PipelinePrompts {
+		add(pp.Prompt.GetInputVariables())
+		produced = append(produced, pp.Name)
+	}
+	add(p.FinalPrompt.GetInputVariables())
+	return inputVariables
+}
@@ -15,6 +15,9 @@ var (
 	ErrInvalidPartialVariableType = errors.New("invalid partial variable type")
 	// ErrNeedChatMessageList is returned when the variable is not a list of chat messages.
 	ErrNeedChatMessageList = errors.New("variable should be a list of chat messages")
+	// ErrMissingPlaceholderVariable is returned when the variable of a messages
+	// placeholder is missing.
+	ErrMissingPlaceholderVariable = errors.New("missing messages placeholder variable")
 )
 
 // PromptTemplate contains common fields for all prompt templates.
@@ -76,6 +79,37 @@ func (p PromptTemplate) GetInputVariables() []string {
 	return p.InputVariables
 }
 
+// Partial returns a copy of the prompt template with some of the variables
+// set. The values must be strings or functions returning a string, called
+// when the prompt is rendered. The variables are removed from the input
+// variables.
+func (p PromptTemplate) Partial(values map[string]any) PromptTemplate {
+	p.PartialVariables = mergePartialValues(p.PartialVariables, values)
+	p.InputVariables = withoutVariables(p.InputVariables, values)
+	return p
+}
+
+func mergePartialValues(partialValues map[string]any, values map[string]any) map[string]any {
+	merged := make(map[string]any, len(partialValues)+len(values))
+	for variable, value := range partialValues {
+		merged[variable] = value
+	}
+	for variable, value := range values {
+		merged[variable] = value
+	}
+	return merged
+}
+
+func withoutVariables(inputVariables []string, values map[string]any) []string {
+	result := make([]string, 0, len(inputVariables))
+	for _, v := range inputVariables {
+		if _, ok := values[v]; !ok {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
 func resolvePartialValues(partialValues map[string]any, values map[string]any) (map[string]any, error) {
 	resolvedValues := make(map[string]any)
 	for variable, value := range partialValues {
@@ -1,6 +1,10 @@
 package prompts
 
-import "github.com/mateors/llmg/llms"
+import (
+	"context"
+
+	"github.com/mateors/llmg/llms"
+)
 
 // Formatter is an interface for formatting a map of values into a string.
 type Formatter interface {
@@ -19,3 +23,34 @@ type FormatPrompter interface {
 	FormatPrompt(values map[string]any) (llms.PromptValue, error)
 	GetInputVariables() []string
 }
+
+// ContextFormatPrompter is implemented by prompts that use a context while
+// formatting, e.g. to select examples with a selector calling an embedder.
+type ContextFormatPrompter interface {
+	FormatPrompter
+	FormatPromptContext(ctx context.Context, values map[string]any) (llms.PromptValue, error)
+}
+
+// ContextMessageFormatter is implemented by message formatters that use a
+// context while formatting, see ContextFormatPrompter.
+type ContextMessageFormatter interface {
+	MessageFormatter
+	FormatMessagesContext(ctx context.Context, values map[string]any) ([]llms.ChatMessage, error)
+}
+
+// FormatPromptContext formats a prompt with ctx if it can use one.
+func FormatPromptContext(ctx context.Context, p FormatPrompter, values map[string]any) (llms.PromptValue, error) { //nolint:ireturn,lll
+	if cp, ok := p.(ContextFormatPrompter); ok {
+		return cp.FormatPromptContext(ctx, values)
+	}
+	return p.FormatPrompt(values)
+}
+
+// FormatMessagesContext formats messages with ctx if the formatter can use
+// one.
+func FormatMessagesContext(ctx context.Context, m MessageFormatter, values map[string]any) ([]llms.ChatMessage, error) {
+	if cm, ok := m.(ContextMessageFormatter); ok {
+		return cm.FormatMessagesContext(ctx, values)
+	}
+	return m.FormatMessages(values)
+}
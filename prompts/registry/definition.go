@@ -0,0 +1,207 @@
+package registry
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mateors/llmg/llms"
+	"github.com/mateors/llmg/prompts"
+)
+
+// Definition is a prompt as stored in a file.
+type Definition struct {
+	// Name identifies the prompt. Defaults to the path of the file without
+	// its extension.
+	Name string `json:"name" yaml:"name"`
+	// Version is the version of the prompt, e.g. "1.2.0". Versions are
+	// compared number by number, see CompareVersions.
+	Version string `json:"version" yaml:"version"`
+	// Description describes what the prompt is for.
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Template is the prompt template. In Markdown files, it is the body
+	// following the frontmatter.
+	Template string `json:"template" yaml:"template"`
+	// Format is the format of the template. Defaults to go-template.
+	Format prompts.TemplateFormat `json:"format,omitempty" yaml:"format,omitempty"`
+	// InputVariables are the variables the template expects.
+	InputVariables []string `json:"input_variables,omitempty" yaml:"input_variables,omitempty"`
+	// PartialVariables are variables with a fixed value.
+	PartialVariables map[string]string `json:"partial_variables,omitempty" yaml:"partial_variables,omitempty"`
+	// Model holds hints on the model the prompt was written for.
+	Model ModelHints `json:"model,omitzero" yaml:"model,omitempty"`
+	// Path is the path of the file the prompt was loaded from.
+	Path string `json:"-" yaml:"-"`
+}
+
+// ModelHints are the model and call options a prompt was written for.
+type ModelHints struct {
+	Name        string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty" yaml:"temperature,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty" yaml:"top_p,omitempty"`
+	StopWords   []string `json:"stop_words,omitempty" yaml:"stop_words,omitempty"`
+}
+
+// CallOptions returns the call options of the hints that are set.
+func (h ModelHints) CallOptions() []llms.CallOption {
+	var opts []llms.CallOption
+	if h.Name != "" {
+		opts = append(opts, llms.WithModel(h.Name))
+	}
+	if h.Temperature != nil {
+		opts = append(opts, llms.WithTemperature(*h.Temperature))
+	}
+	if h.MaxTokens > 0 {
+		opts = append(opts, llms.WithMaxTokens(h.MaxTokens))
+	}
+	if h.TopP != nil {
+		opts = append(opts, llms.WithTopP(*h.TopP))
+	}
+	if len(h.StopWords) > 0 {
+		opts = append(opts, llms.WithStopWords(h.StopWords))
+	}
+	return opts
+}
+
+// PromptTemplate returns the prompt template of the definition.
+func (d Definition) PromptTemplate() prompts.PromptTemplate {
+	p := prompts.PromptTemplate{
+		Template:       d.Template,
+		InputVariables: d.InputVariables,
+		TemplateFormat: d.Format,
+	}
+	if len(d.PartialVariables) > 0 {
+		p.PartialVariables = make(map[string]any, len(d.PartialVariables))
+		for k, v := range d.PartialVariables {
+			p.PartialVariables[k] = v
+		}
+	}
+	return p
+}
+
+// validate checks that the template renders with its variables.
+func (d Definition) validate() error {
+	variables := append([]string{}, d.InputVariables...)
+	for k := range d.PartialVariables {
+		variables = append(variables, k)
+	}
+	return prompts.CheckValidTemplate(d.Template, d.Format, variables)
+}
+
+// parseDefinition parses a file by its extension. ok is false for files that
+// are not prompts: YAML and JSON files that are not an object with a template
+// field, and Markdown files without a frontmatter.
+func parseDefinition(name string, data []byte) (def Definition, ok bool, err error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		var fields map[string]any
+		if yaml.Unmarshal(data, &fields) != nil || !hasTemplate(fields) {
+			return def, false, nil
+		}
+		err = yaml.Unmarshal(data, &def)
+	case ".json":
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(data, &fields) != nil || !hasTemplate(fields) {
+			return def, false, nil
+		}
+		err = json.Unmarshal(data, &def)
+	case ".md", ".markdown":
+		var frontmatter, body []byte
+		frontmatter, body, ok = splitFrontmatter(data)
+		if !ok {
+			return def, false, nil
+		}
+		err = yaml.Unmarshal(frontmatter, &def)
+		def.Template = strings.TrimSuffix(string(body), "\n")
+	default:
+		return def, false, nil
+	}
+	if err != nil {
+		return def, true, err
+	}
+
+	def.Path = name
+	if def.Name == "" {
+		def.Name = strings.TrimSuffix(name, path.Ext(name))
+	}
+	if def.Format == "" {
+		def.Format = prompts.TemplateFormatGoTemplate
+	}
+	return def, true, nil
+}
+
+// hasTemplate reports whether the fields of a file include the template, which
+// marks YAML and JSON files as prompts.
+func hasTemplate[V any](fields map[string]V) bool {
+	_, ok := fields["template"]
+	return ok
+}
+
+// splitFrontmatter splits a Markdown file starting with a YAML frontmatter
+// delimited by "---" lines.
+func splitFrontmatter(data []byte) (frontmatter, body []byte, ok bool) {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	rest, ok := bytes.CutPrefix(data, []byte("---\n"))
+	if !ok {
+		return nil, nil, false
+	}
+	if after, ok := bytes.CutPrefix(rest, []byte("---\n")); ok {
+		return nil, after, true
+	}
+	frontmatter, body, ok = bytes.Cut(rest, []byte("\n---\n"))
+	if !ok {
+		frontmatter, ok = bytes.CutSuffix(rest, []byte("\n---"))
+	}
+	return frontmatter, bytes.TrimPrefix(body, []byte("\n")), ok
+}
+
+// CompareVersions compares two versions number by number, ignoring a leading
+// "v": it returns -1 if a < b, 0 if a == b and +1 if a > b. Parts that are not
+// numbers are compared as strings.
+func CompareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := range max(len(as), len(bs)) {
+		var pa, pb string
+		if i < len(as) {
+			pa = as[i]
+		}
+		if i < len(bs) {
+			pb = bs[i]
+		}
+		if c := comparePart(pa, pb); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func comparePart(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case a == b:
+		return 0
+	case errA == nil && errB == nil:
+		return cmp.Compare(na, nb)
+	case a == "":
+		return -1
+	case b == "":
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func (d Definition) String() string {
+	if d.Version == "" {
+		return d.Name
+	}
+	return fmt.Sprintf("%s@%s", d.Name, d.Version)
+}
@@ -0,0 +1,230 @@
+// Package registry loads prompt templates from files, so that prompts can be
+// versioned and edited without rebuilding the program.
+//
+// A prompt is a YAML or JSON file holding a Definition, including its template,
+// or a Markdown file whose body is the template, preceded by the other fields
+// as a YAML frontmatter:
+//
+//	---
+//	name: summarize
+//	version: 1.1.0
+//	input_variables: [text]
+//	model:
+//	  temperature: 0.2
+//	---
+//	Summarize the following text:
+//	{{.text}}
+//
+// Other files are ignored, so that prompts can live next to configuration or
+// fixtures: YAML and JSON files that are not an object with a template field,
+// Markdown files without a frontmatter and files of other types.
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrPromptNotFound is returned when no prompt has the name looked up.
+	ErrPromptNotFound = errors.New("prompt not found")
+	// ErrVersionNotFound is returned when a prompt has no such version.
+	ErrVersionNotFound = errors.New("prompt version not found")
+	// ErrInvalidPrompt is returned when a prompt file cannot be parsed or its
+	// template is invalid.
+	ErrInvalidPrompt = errors.New("invalid prompt")
+	// ErrDuplicatePrompt is returned when two files define the same version of
+	// a prompt.
+	ErrDuplicatePrompt = errors.New("duplicate prompt")
+)
+
+// Registry holds the prompts loaded from a file system, every version of
+// them, by name. It is safe for concurrent use.
+type Registry struct {
+	fsys fs.FS
+
+	mu      sync.RWMutex
+	prompts map[string][]Definition
+	stamp   string
+}
+
+// New creates a registry of the prompts found in fsys. It fails if any of the
+// prompts is invalid.
+func New(fsys fs.FS) (*Registry, error) {
+	r := &Registry{fsys: fsys}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewFromDir creates a registry of the prompts found in the directory dir.
+func NewFromDir(dir string) (*Registry, error) {
+	return New(os.DirFS(dir))
+}
+
+// Get returns the latest version of a prompt.
+func (r *Registry) Get(name string) (Definition, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	versions, ok := r.prompts[name]
+	if !ok {
+		return Definition{}, fmt.Errorf("%w: %s", ErrPromptNotFound, name)
+	}
+	return versions[len(versions)-1], nil
+}
+
+// GetVersion returns a version of a prompt.
+func (r *Registry) GetVersion(name, version string) (Definition, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	versions, ok := r.prompts[name]
+	if !ok {
+		return Definition{}, fmt.Errorf("%w: %s", ErrPromptNotFound, name)
+	}
+	for _, def := range versions {
+		if CompareVersions(def.Version, version) == 0 {
+			return def, nil
+		}
+	}
+	return Definition{}, fmt.Errorf("%w: %s@%s", ErrVersionNotFound, name, version)
+}
+
+// Names returns the names of the prompts, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.prompts))
+	for name := range r.prompts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Versions returns the versions of a prompt, from the oldest to the latest.
+func (r *Registry) Versions(name string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	versions := make([]string, 0, len(r.prompts[name]))
+	for _, def := range r.prompts[name] {
+		versions = append(versions, def.Version)
+	}
+	return versions
+}
+
+// Reload loads the prompts again. If any prompt is invalid, the prompts
+// loaded before are kept and the error is returned.
+func (r *Registry) Reload() error {
+	stamp, err := r.fingerprint()
+	if err != nil {
+		return err
+	}
+	prompts, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prompts = prompts
+	r.stamp = stamp
+	return nil
+}
+
+// Watch checks the files for changes every interval and reloads the prompts
+// when any file was added, removed or modified, until ctx is done. Errors
+// of the reloads are passed to onError, which may be nil, once per change.
+// Watch blocks, it is meant to be run in its own goroutine.
+func (r *Registry) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	r.mu.RLock()
+	seen := r.stamp
+	r.mu.RUnlock()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stamp, err := r.fingerprint()
+		if err == nil {
+			if stamp == seen {
+				continue
+			}
+			seen = stamp
+			err = r.Reload()
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+func (r *Registry) load() (map[string][]Definition, error) {
+	prompts := make(map[string][]Definition)
+	err := fs.WalkDir(r.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(r.fsys, name)
+		if err != nil {
+			return err
+		}
+
+		def, ok, err := parseDefinition(name, data)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrInvalidPrompt, name, err)
+		}
+		if !ok {
+			return nil
+		}
+		if err := def.validate(); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrInvalidPrompt, name, err)
+		}
+		for _, other := range prompts[def.Name] {
+			if CompareVersions(other.Version, def.Version) == 0 {
+				return fmt.Errorf("%w: %s in %s and %s", ErrDuplicatePrompt, def, other.Path, def.Path)
+			}
+		}
+		prompts[def.Name] = append(prompts[def.Name], def)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, versions := range prompts {
+		slices.SortStableFunc(versions, func(a, b Definition) int {
+			return CompareVersions(a.Version, b.Version)
+		})
+	}
+	return prompts, nil
+}
+
+// fingerprint summarizes the names, sizes and modification times of the
+// files, to detect changes.
+func (r *Registry) fingerprint() (string, error) {
+	var stamp []byte
+	err := fs.WalkDir(r.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		stamp = fmt.Appendf(stamp, "%s\x00%d\x00%d\n", name, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return string(stamp), err
+}
@@ -0,0 +1,100 @@
+package prompts
+
+import (
+	"container/list"
+	"sync"
+)
+
+const _defaultTemplateCacheSize = 512
+
+// templateCache is a least recently used cache of compiled templates, so that
+// formatting the same template again does not parse it again.
+type templateCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[templateCacheKey]*list.Element
+}
+
+type templateCacheKey struct {
+	format   TemplateFormat
+	template string
+}
+
+type templateCacheEntry struct {
+	key      templateCacheKey
+	compiled any
+}
+
+var compiledTemplates = newTemplateCache(_defaultTemplateCacheSize) //nolint:gochecknoglobals
+
+func newTemplateCache(size int) *templateCache {
+	return &templateCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[templateCacheKey]*list.Element),
+	}
+}
+
+// SetTemplateCacheSize sets how many compiled templates are kept, 512 by
+// default. Zero disables the cache.
+func SetTemplateCacheSize(size int) {
+	compiledTemplates.mu.Lock()
+	defer compiledTemplates.mu.Unlock()
+	compiledTemplates.size = size
+	compiledTemplates.evict()
+}
+
+// compile returns the compiled template from the cache, or compiles it with
+// compileFn and adds it. Compiled templates must be safe for concurrent use.
+func compile[T any](format TemplateFormat, template string, compileFn func(string) (T, error)) (T, error) {
+	key := templateCacheKey{format: format, template: template}
+	if compiled, ok := compiledTemplates.get(key); ok {
+		if t, ok := compiled.(T); ok {
+			return t, nil
+		}
+	}
+
+	t, err := compileFn(template)
+	if err != nil {
+		return t, err
+	}
+	compiledTemplates.add(key, t)
+	return t, nil
+}
+
+func (c *templateCache) get(key templateCacheKey) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*templateCacheEntry).compiled, true //nolint:forcetypeassert
+}
+
+func (c *templateCache) add(key templateCacheKey, compiled any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size <= 0 {
+		return
+	}
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*templateCacheEntry).compiled = compiled //nolint:forcetypeassert
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&templateCacheEntry{key: key, compiled: compiled})
+	c.evict()
+}
+
+// evict removes the least recently used templates above the size. Must be
+// called with mu held.
+func (c *templateCache) evict() {
+	for c.order.Len() > max(c.size, 0) {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.entries, e.Value.(*templateCacheEntry).key) //nolint:forcetypeassert
+	}
+}
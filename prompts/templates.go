@@ -42,10 +42,7 @@ var defaultFormatterMapping = map[TemplateFormat]interpolator{ //nolint:gocheckn
 // interpolateGoTemplate interpolates the given template with the given values by using
 // text/template.
 func interpolateGoTemplate(tmpl string, values map[string]any) (string, error) {
-	parsedTmpl, err := template.New("template").
-		Option("missingkey=error").
-		Funcs(sprig.TxtFuncMap()).
-		Parse(tmpl)
+	parsedTmpl, err := compile(TemplateFormatGoTemplate, tmpl, parseGoTemplate)
 	if err != nil {
 		return "", err
 	}
@@ -57,10 +54,17 @@ func interpolateGoTemplate(tmpl string, values map[string]any) (string, error) {
 	return sb.String(), nil
 }
 
+func parseGoTemplate(tmpl string) (*template.Template, error) {
+	return template.New("template").
+		Option("missingkey=error").
+		Funcs(sprig.TxtFuncMap()).
+		Parse(tmpl)
+}
+
 // interpolateJinja2 interpolates the given template with the given values by using
 // jinja2(impl by https://github.com/NikolaLohinski/gonja).
 func interpolateJinja2(tmpl string, values map[string]any) (string, error) {
-	tpl, err := gonja.FromString(tmpl)
+	tpl, err := compile(TemplateFormatJinja2, tmpl, gonja.FromString)
 	if err != nil {
 		return "", err
 	}
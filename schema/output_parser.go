@@ -1,6 +1,10 @@
 package schema
 
-import "github.com/mateors/llmg/llms"
+import (
+	"context"
+
+	"github.com/mateors/llmg/llms"
+)
 
 // OutputParser is an interface for parsing the output of an LLM call.
 type OutputParser[T any] interface {
@@ -13,3 +17,13 @@ type OutputParser[T any] interface {
 	// Type returns the string type key uniquely identifying this class of parser
 	Type() string
 }
+
+// ContextOutputParser is an output parser that can use a context while
+// parsing, e.g. to call a model. Chains use ParseWithPromptContext when their
+// parser implements it.
+type ContextOutputParser[T any] interface {
+	OutputParser[T]
+	// ParseWithPromptContext parses the output of an LLM call with the prompt
+	// used.
+	ParseWithPromptContext(ctx context.Context, text string, prompt llms.PromptValue) (T, error)
+}
@@ -34,8 +34,9 @@ func (c Calculator) Name() string {
 // agent the ability to retry.
 func (c Calculator) Call(ctx context.Context, input string) (string, error) {
 
-	if c.CallbacksHandler != nil {
-		c.CallbacksHandler.HandleToolStart(ctx, input)
+	handler := callbacks.Combine(ctx, c.CallbacksHandler)
+	if handler != nil {
+		handler.HandleToolStart(ctx, input)
 	}
 
 	thread := &starlark.Thread{Name: "main"}
@@ -46,8 +47,8 @@ func (c Calculator) Call(ctx context.Context, input string) (string, error) {
 	}
 	result := v.String()
 
-	if c.CallbacksHandler != nil {
-		c.CallbacksHandler.HandleToolEnd(ctx, result)
+	if handler != nil {
+		handler.HandleToolEnd(ctx, result)
 	}
 
 	return result, nil
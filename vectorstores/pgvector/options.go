@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/mateors/llmg/callbacks"
 	"github.com/mateors/llmg/embeddings"
 )
 
@@ -72,6 +73,14 @@ func WithConn(conn PGXConn) Option {
 	}
 }
 
+// WithCallbacksHandler is an option for setting the handler receiving the
+// vector store events.
+func WithCallbacksHandler(handler callbacks.Handler) Option {
+	return func(p *Store) {
+		p.callbacksHandler = handler
+	}
+}
+
 // WithCollectionMetadata is an option for specifying the collection metadata.
 func WithCollectionMetadata(metadata map[string]any) Option {
 	return func(p *Store) {
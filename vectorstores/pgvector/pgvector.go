@@ -12,6 +12,7 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/pgvector/pgvector-go"
 
+	"github.com/mateors/llmg/callbacks"
 	"github.com/mateors/llmg/embeddings"
 	"github.com/mateors/llmg/schema"
 	"github.com/mateors/llmg/vectorstores"
@@ -64,6 +65,7 @@ type Store struct {
 	preDeleteCollection bool
 	vectorDimensions    int
 	hnswIndex           *HNSWIndex
+	callbacksHandler    callbacks.Handler
 }
 
 type HNSWIndex struct {
@@ -230,6 +232,28 @@ func (s Store) AddDocuments(
 	ctx context.Context,
 	docs []schema.Document,
 	options ...vectorstores.Option,
+) ([]string, error) {
+	ctx, _ = callbacks.StartRun(ctx, callbacks.RunTypeVectorStore, "pgvector.add")
+	handler, _ := callbacks.Combine(ctx, s.callbacksHandler).(callbacks.VectorStoreHandler)
+	if handler != nil {
+		handler.HandleVectorStoreAddStart(ctx, docs)
+	}
+
+	ids, err := s.addDocuments(ctx, docs, options...)
+	if handler != nil {
+		if err != nil {
+			handler.HandleVectorStoreError(ctx, err)
+		} else {
+			handler.HandleVectorStoreAddEnd(ctx, ids)
+		}
+	}
+	return ids, err
+}
+
+func (s Store) addDocuments(
+	ctx context.Context,
+	docs []schema.Document,
+	options ...vectorstores.Option,
 ) ([]string, error) {
 	opts := s.getOptions(options...)
 	if opts.ScoreThreshold != 0 || opts.Filters != nil || opts.NameSpace != "" {
@@ -269,12 +293,36 @@ func (s Store) AddDocuments(
 	return ids, s.conn.SendBatch(ctx, b).Close()
 }
 
-//nolint:cyclop
+// SimilaritySearch returns the numDocuments documents closest to the query.
 func (s Store) SimilaritySearch(
 	ctx context.Context,
 	query string,
 	numDocuments int,
 	options ...vectorstores.Option,
+) ([]schema.Document, error) {
+	ctx, _ = callbacks.StartRun(ctx, callbacks.RunTypeVectorStore, "pgvector.search")
+	handler, _ := callbacks.Combine(ctx, s.callbacksHandler).(callbacks.VectorStoreHandler)
+	if handler != nil {
+		handler.HandleVectorStoreSearchStart(ctx, query, numDocuments)
+	}
+
+	docs, err := s.similaritySearch(ctx, query, numDocuments, options...)
+	if handler != nil {
+		if err != nil {
+			handler.HandleVectorStoreError(ctx, err)
+		} else {
+			handler.HandleVectorStoreSearchEnd(ctx, docs)
+		}
+	}
+	return docs, err
+}
+
+//nolint:cyclop
+func (s Store) similaritySearch(
+	ctx context.Context,
+	query string,
+	numDocuments int,
+	options ...vectorstores.Option,
 ) ([]schema.Document, error) {
 	opts := s.getOptions(options...)
 	collectionName := s.getNameSpace(opts)
@@ -396,6 +444,26 @@ LIMIT $1`, s.embeddingTableName, s.embeddingTableName, s.embeddingTableName,
 	return docs, rows.Err()
 }
 
+// Delete removes the documents with the given ids from the collection.
+func (s Store) Delete(ctx context.Context, ids []string) error {
+	ctx, _ = callbacks.StartRun(ctx, callbacks.RunTypeVectorStore, "pgvector.delete")
+	handler, _ := callbacks.Combine(ctx, s.callbacksHandler).(callbacks.VectorStoreHandler)
+	if handler != nil {
+		handler.HandleVectorStoreDeleteStart(ctx, ids)
+	}
+
+	sql := fmt.Sprintf(`DELETE FROM %s WHERE uuid = ANY($1) AND collection_id = $2`, s.embeddingTableName)
+	_, err := s.conn.Exec(ctx, sql, ids, s.collectionUUID)
+	if handler != nil {
+		if err != nil {
+			handler.HandleVectorStoreError(ctx, err)
+		} else {
+			handler.HandleVectorStoreDeleteEnd(ctx, ids)
+		}
+	}
+	return err
+}
+
 func (s Store) DropTables(ctx context.Context) error {
 	if _, err := s.conn.Exec(ctx, fmt.Sprintf(`DROP TABLE IF EXISTS %s`, s.embeddingTableName)); err != nil {
 		return err
@@ -26,8 +26,10 @@ var _ schema.Retriever = Retriever{}
 
 // GetRelevantDocuments returns documents using the vector store.
 func (r Retriever) GetRelevantDocuments(ctx context.Context, query string) ([]schema.Document, error) {
-	if r.CallbacksHandler != nil {
-		r.CallbacksHandler.HandleRetrieverStart(ctx, query)
+	ctx, _ = callbacks.StartRun(ctx, callbacks.RunTypeRetriever, "Retriever")
+	handler := callbacks.Combine(ctx, r.CallbacksHandler)
+	if handler != nil {
+		handler.HandleRetrieverStart(ctx, query)
 	}
 
 	docs, err := r.v.SimilaritySearch(ctx, query, r.numDocs, r.options...)
@@ -35,8 +37,8 @@ func (r Retriever) GetRelevantDocuments(ctx context.Context, query string) ([]sc
 		return nil, err
 	}
 
-	if r.CallbacksHandler != nil {
-		r.CallbacksHandler.HandleRetrieverEnd(ctx, query, docs)
+	if handler != nil {
+		handler.HandleRetrieverEnd(ctx, query, docs)
 	}
 
 	return docs, nil